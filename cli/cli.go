@@ -2,28 +2,106 @@ package cli
 
 import (
 	"cmp"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	fmm "github.com/raiguard/fmm/lib"
+	"golang.org/x/term"
 )
 
 const usageStr string = `usage: fmm <command> [args...]
 commands:
   add     [args...]   Download and enable the given mods and their dependencies.
+  autoremove          Disable mods that were only enabled as dependencies and are no longer required.
+  clean               Delete the zip files of every release except the enabled release and the newest releases of each mod.
+                      Releases in the lockfile and the newest release matching each pin are kept.
   disable [args...]   Disable the given mods, or all mods if none are given.
+                      With --cascade, also disable every mod that requires the given mods.
+  edit-details <mod> <file>
+                      Update the mod portal page of the given mod with the details in the given JSON file.
   enable  [args...]   Enable the given mods and their dependencies.
+  graph   [args...]   Print the dependency graph of the given mods, or of the enabled mods if none are given.
   help                Show usage information.
+  info    [mods...]   Show the local and mod portal information of the given mods.
   list    [files...]  List all mods in the mods directory, or in the given save files.
+  load-order          List the enabled mods in the order that Factorio will load them.
+  lock    [file]      Write the exact releases of the enabled mods to a lockfile (default: mods/fmm-lock.json).
+  login   [username]  Log in to the Factorio authentication server and store the service token for downloading mods.
+                      The password is read from $FACTORIO_PASSWORD, or prompted for.
+  pin     [pins...]   Pin mods to a version or constraint (e.g. flib, flib=0.12.0, "flib < 0.14"), or list pins.
+                      Pinned mods are never added, synced or updated to a release outside of their pin.
+  publish <file> [details]
+                      Publish the given mod zip file to the mod portal as a new mod, with the details in the given JSON file.
+  rdeps   [mods...]   List the enabled mods that depend on the given mods.
+  remove  [mods...]   Delete the given releases from the mods directory, or every release of mods given without a version.
+                      Mods are disabled if their enabled release is removed.
+  search  [query...]  Search the mod portal for mods compatible with the current game version.
+                      When piped, only the mod names are printed, so they can be piped into add.
   sync    [args...]   Disable all mods, then download and enable the given mods and their dependencies.
                       If a save file is provided, merge startup mod settings with the settings contained in that save.
-  update  [args...]   Update the given mods, or all mods if none are given.
-  upload  [files...]  Upload the given mod zip files to the mod portal.`
+                      With --locked, enable exactly the releases in the given lockfile instead.
+  unpin   [mods...]   Remove the pins on the given mods.
+  update  [args...]   Update the given mods, or all mods if none are given, and show their changelogs.
+                      With --preview, only show the changelogs of the available updates.
+  upload  [files...]  Upload the given mod zip files to the mod portal.
+  why     [mods...]   Show the chain of dependencies that caused the given mods to be enabled.
+options:
+  --strict            Fail if any file in the mods directory is not a valid mod, instead of skipping it with a warning.
+  --force             Save changes even if add, enable or sync make the enabled mods incompatible with each other.
+  --optional          Also add or enable optional dependencies.
+  --hidden-optional   Also add or enable optional and hidden optional dependencies.
+  --format <format>   The output format of graph, either dot (default) or json, or of update, either text (default) or json.
+  --keep <n>          The number of newest releases of each mod that clean keeps (default: 1).
+  --dry-run           List the releases that clean would delete without deleting them.
+  --preview           Show the available updates without downloading them.
+  --delete            Also delete the files of mods that are disabled by autoremove.
+  --locked            Sync to the releases in a lockfile without resolving dependencies.
+  --cascade           Also disable the mods that require the mods being disabled.
+  --auth-url <url>    Use the authentication server at the given URL for login (default: $FACTORIO_AUTH_URL or https://auth.factorio.com).
+  --portal <url>      Use the mod portal at the given URL (default: $FACTORIO_PORTAL_URL or https://mods.factorio.com).
+  --offline           Only use cached mod portal information, and never download anything.
+  --cache-ttl <ttl>   How long cached mod portal information is used before it is revalidated (default: 1h).
+  --parallel <n>      The number of mods to download at the same time (default: 4).
+  --factorio-version <version>
+                      Search for mods compatible with the given Factorio version instead (e.g. 1.1).
+  --category <name>   Only show search results in the given category.
+  --owner <name>      Only show search results owned by the given user.
+  --page <n>          The page of search results to show (default: 1).
+  --page-size <n>     The number of search results on each page (default: 20).`
+
+var (
+	force          bool
+	strict         bool
+	optional       bool
+	hiddenOptional bool
+	format         string
+	doDelete       bool
+	locked         bool
+	cascade        bool
+	portalUrl      string
+	authUrl        string
+	offline        bool
+	cacheTTL       time.Duration
+	parallel       int
+	preview        bool
+	keep           int
+	dryRun         bool
+
+	factorioVersion string
+	category        string
+	owner           string
+	page            int
+	pageSize        int
+)
 
 func Run(args []string) {
 	if len(args) == 0 {
@@ -31,27 +109,111 @@ func Run(args []string) {
 	}
 
 	var task func(*fmm.Manager, []string)
+	readOnly := false
+	// Tasks that do not need a game directory are given a nil Manager
+	needsManager := true
+	// Only tasks that enable mods are stopped from saving if they introduce
+	// incompatibilities. Tasks that delete files must always save afterwards,
+	// or mod-list.json would refer to releases that no longer exist.
+	enablesMods := false
 	switch args[0] {
 	case "add", "a":
 		task = add
+		enablesMods = true
+	case "autoremove":
+		task = autoremove
+	case "clean":
+		task = clean
 	case "disable", "d":
 		task = disable
+	case "edit-details":
+		task = editDetails
+		readOnly = true
 	case "enable", "e":
 		task = enable
+		enablesMods = true
+	case "graph":
+		task = graph
+		readOnly = true
 	case "help", "h", "-h", "--help", "-help":
 		printUsage()
+	case "info", "i":
+		task = info
+		readOnly = true
 	case "list", "ls":
 		task = list
+		readOnly = true
+	case "lock":
+		task = lock
+		readOnly = true
+	case "login":
+		task = login
+		needsManager = false
+	case "load-order":
+		task = loadOrder
+		readOnly = true
+	case "pin":
+		task = pin
+	case "publish":
+		task = publish
+		readOnly = true
+	case "remove", "rm":
+		task = remove
+	case "rdeps":
+		task = rdeps
+		readOnly = true
+	case "search":
+		task = search
+		readOnly = true
 	case "sync", "s":
 		task = sync
+		enablesMods = true
+	case "unpin":
+		task = unpin
 	case "update", "u":
 		task = update
 	case "upload", "ul":
 		task = upload
+		readOnly = true
+	case "why":
+		task = why
+		readOnly = true
 	default:
 		printUsage("unrecognized operation", args[0])
 	}
-	args = args[1:]
+
+	flags := flag.NewFlagSet("fmm", flag.ExitOnError)
+	flags.Usage = func() { printUsage() }
+	flags.BoolVar(&force, "force", false, "")
+	flags.BoolVar(&strict, "strict", false, "")
+	flags.BoolVar(&optional, "optional", false, "")
+	flags.BoolVar(&hiddenOptional, "hidden-optional", false, "")
+	flags.StringVar(&format, "format", "", "")
+	flags.BoolVar(&doDelete, "delete", false, "")
+	flags.BoolVar(&locked, "locked", false, "")
+	flags.BoolVar(&cascade, "cascade", false, "")
+	flags.StringVar(&portalUrl, "portal", os.Getenv("FACTORIO_PORTAL_URL"), "")
+	flags.StringVar(&authUrl, "auth-url", os.Getenv("FACTORIO_AUTH_URL"), "")
+	flags.BoolVar(&offline, "offline", false, "")
+	flags.DurationVar(&cacheTTL, "cache-ttl", time.Hour, "")
+	flags.IntVar(&parallel, "parallel", fmm.DefaultDownloadParallelism, "")
+	flags.BoolVar(&preview, "preview", false, "")
+	flags.IntVar(&keep, "keep", fmm.DefaultCleanKeep, "")
+	flags.BoolVar(&dryRun, "dry-run", false, "")
+	flags.StringVar(&factorioVersion, "factorio-version", "", "")
+	flags.StringVar(&category, "category", "", "")
+	flags.StringVar(&owner, "owner", "", "")
+	flags.IntVar(&page, "page", 1, "")
+	flags.IntVar(&pageSize, "page-size", fmm.DefaultSearchPageSize, "")
+	args = parseFlags(flags, args[1:])
+
+	if authUrl == "" {
+		authUrl = fmm.DefaultAuthUrl
+	}
+	if !needsManager {
+		task(nil, args)
+		return
+	}
 
 	manager, err := fmm.NewManager(".", filepath.Join(".", "mods"))
 	if err != nil {
@@ -69,6 +231,15 @@ func Run(args []string) {
 		}
 	}
 
+	if diagnostics := manager.GetDiagnostics(); len(diagnostics) > 0 {
+		for _, diagnostic := range diagnostics {
+			errorln("warning: skipped", diagnostic.ToString())
+		}
+		if strict {
+			abort("aborting due to invalid files in the mods directory")
+		}
+	}
+
 	if !manager.HasPlayerData() {
 		manager.SetPlayerData(fmm.PlayerData{
 			Token:    os.Getenv("FACTORIO_TOKEN"),
@@ -78,15 +249,52 @@ func Run(args []string) {
 
 	manager.SetApiKey(os.Getenv("FACTORIO_API_KEY"))
 
+	if portalUrl != "" {
+		manager.SetPortalUrl(portalUrl)
+	}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		manager.SetPortalCache(filepath.Join(cacheDir, "fmm", "portal"), cacheTTL)
+	}
+	manager.SetOffline(offline)
+	manager.SetDownloadParallelism(parallel)
+	manager.SetDownloadProgressHandler(printDownloadProgress)
+	manager.SetStatusHandler(func(msg string) { fmt.Println(msg) })
+
 	stdinStat, _ := os.Stdin.Stat()
 	if stdinStat.Mode()&os.ModeNamedPipe > 0 {
 		bytes, err := io.ReadAll(os.Stdin)
 		if err == nil {
-			args = append(args, strings.Split(strings.TrimSpace(string(bytes)), "\n")...)
+			args = append(args, parsePipedArgs(string(bytes))...)
+		}
+	}
+
+	// Conflicts that already existed should not block the task
+	existing := map[string]bool{}
+	if enablesMods {
+		for _, incompatibility := range manager.CheckIncompatibilities() {
+			existing[incompatibility.ToString()] = true
 		}
 	}
 
 	task(manager, args)
+	if readOnly {
+		return
+	}
+
+	if enablesMods {
+		introduced := []fmm.Incompatibility{}
+		for _, incompatibility := range manager.CheckIncompatibilities() {
+			if !existing[incompatibility.ToString()] {
+				introduced = append(introduced, incompatibility)
+			}
+		}
+		for _, incompatibility := range introduced {
+			errorln(incompatibility.ToString())
+		}
+		if len(introduced) > 0 && !force {
+			abort("refusing to save incompatible mods, use --force to save anyway")
+		}
+	}
 
 	if err := manager.Save(); err != nil {
 		errorln(errors.Join(errors.New("unable to save modifications"), err))
@@ -95,15 +303,42 @@ func Run(args []string) {
 
 func add(manager *fmm.Manager, args []string) {
 	mods, _ := getMods(args)
-	for _, mod := range manager.ExpandDependencies(mods, true) {
-		ver, err := manager.Add(mod)
-		if err != nil {
-			errorf("failed to add %s\n", mod.ToString())
-			errorln(err)
-		} else if ver != nil {
-			fmt.Println("enabled", mod.Name, ver.ToString(false))
+	enableResolved(manager, mods, true)
+}
+
+func autoremove(manager *fmm.Manager, args []string) {
+	mods, err := manager.Autoremove(doDelete)
+	for _, mod := range mods {
+		if doDelete {
+			fmt.Println("deleted", mod.ToString())
+		} else {
+			fmt.Println("disabled", mod.ToString())
 		}
 	}
+	if err != nil {
+		errorln(err)
+	}
+}
+
+func clean(manager *fmm.Manager, args []string) {
+	cleaned, err := manager.Clean(keep, dryRun)
+	verb := "removed"
+	if dryRun {
+		verb = "would remove"
+	}
+	var total int64
+	for _, release := range cleaned {
+		fmt.Printf("%s %s %s (%s)\n", verb, release.Name, release.Version.ToString(false), formatBytes(release.Size))
+		total += release.Size
+	}
+	if err != nil {
+		errorln(err)
+	}
+	if dryRun {
+		fmt.Printf("%s would be freed\n", formatBytes(total))
+	} else {
+		fmt.Printf("freed %s\n", formatBytes(total))
+	}
 }
 
 func disable(manager *fmm.Manager, args []string) {
@@ -114,6 +349,19 @@ func disable(manager *fmm.Manager, args []string) {
 	}
 
 	mods, _ := getMods(args)
+	names := []string{}
+	for _, mod := range mods {
+		names = append(names, mod.Name)
+	}
+	dependents := manager.GetRequiringDependents(names)
+	if cascade && len(dependents) > 0 {
+		fmt.Println("the following mods require the given mods and will also be disabled:")
+		for _, dependent := range dependents {
+			fmt.Println(" ", dependent.ToString())
+			mods = append(mods, dependent)
+		}
+	}
+
 	for _, mod := range mods {
 		if err := manager.Disable(mod.Name); err != nil {
 			errorf("failed to disable %s\n", mod.ToString())
@@ -122,18 +370,66 @@ func disable(manager *fmm.Manager, args []string) {
 			fmt.Println("disabled", mod.Name)
 		}
 	}
+
+	if !cascade && len(dependents) > 0 {
+		errorln("warning: the following enabled mods require a disabled mod and will fail to load:")
+		for _, dependent := range dependents {
+			errorln(" ", dependent.ToString())
+		}
+		errorln("use --cascade to disable them as well")
+	}
+}
+
+func editDetails(manager *fmm.Manager, args []string) {
+	if len(args) != 2 {
+		printUsage("edit-details requires a mod name and a details file")
+	}
+	details, err := fmm.ParseModDetails(args[1])
+	if err != nil {
+		abort(err)
+	}
+	if err := manager.Portal.EditModDetails(args[0], details); err != nil {
+		abort(err)
+	}
+	fmt.Println("updated details of", args[0])
 }
 
 func enable(manager *fmm.Manager, args []string) {
 	mods, _ := getMods(args)
-	for _, mod := range manager.ExpandDependencies(mods, false) {
-		ver, err := manager.Enable(mod)
+	enableResolved(manager, mods, false)
+}
+
+func graph(manager *fmm.Manager, args []string) {
+	mods, _ := getMods(args)
+	graph := manager.Graph(mods)
+	switch format {
+	case "", "dot":
+		if err := graph.WriteDot(os.Stdout); err != nil {
+			abort(err)
+		}
+	case "json":
+		marshaled, err := json.MarshalIndent(graph, "", "  ")
 		if err != nil {
-			errorf("failed to enable %s\n", mod.ToString())
-			errorln(err)
-		} else if ver != nil {
-			fmt.Println("enabled", mod.Name, ver.ToString(false))
+			abort(err)
 		}
+		fmt.Println(string(marshaled))
+	default:
+		abort("unrecognized graph format", format)
+	}
+}
+
+func info(manager *fmm.Manager, args []string) {
+	mods, _ := getMods(args)
+	for i, mod := range mods {
+		if i > 0 {
+			fmt.Println()
+		}
+		info, err := manager.GetModInfo(mod.Name)
+		if err != nil {
+			errorf("%s: %s\n", mod.Name, err)
+			continue
+		}
+		printModInfo(manager, info)
 	}
 }
 
@@ -171,34 +467,360 @@ func list(manager *fmm.Manager, args []string) {
 	}
 }
 
-func sync(manager *fmm.Manager, args []string) {
-	manager.DisableAll()
-	fmt.Println("disabled all mods")
-	mods, settings := getMods(args)
-	for _, mod := range manager.ExpandDependencies(mods, true) {
-		ver, err := manager.Add(mod)
+func lock(manager *fmm.Manager, args []string) {
+	path := manager.GetLockfilePath()
+	if len(args) > 0 {
+		path = args[0]
+	}
+	lockfile, err := manager.Lock()
+	if err != nil {
+		abort(err)
+	}
+	for _, mod := range lockfile.Mods {
+		if mod.Sha1 == "" && !mod.Internal {
+			errorf("warning: %s is not a zip file and will not be verified\n", mod.FileName)
+		}
+	}
+	if err := lockfile.Write(path); err != nil {
+		abort(err)
+	}
+	fmt.Printf("locked %d mods to %s\n", len(lockfile.Mods), path)
+}
+
+func login(_ *fmm.Manager, args []string) {
+	username := os.Getenv("FACTORIO_USERNAME")
+	if len(args) > 0 {
+		username = args[0]
+	}
+	if username == "" {
+		username = prompt("username: ", false)
+	}
+	password := os.Getenv("FACTORIO_PASSWORD")
+	if password == "" {
+		password = prompt("password: ", true)
+	}
+
+	playerData, err := fmm.Login(authUrl, username, password, "")
+	if errors.Is(err, fmm.ErrAuthCodeRequired) {
+		errorln(err)
+		playerData, err = fmm.Login(authUrl, username, password, prompt("code: ", false))
+	}
+	if err != nil {
+		abort(err)
+	}
+	if err := fmm.SaveCredentials(playerData); err != nil {
+		abort(err)
+	}
+	path, _ := fmm.GetCredentialsPath()
+	fmt.Printf("logged in as %s, credentials saved to %s\n", playerData.Username, path)
+}
+
+func loadOrder(manager *fmm.Manager, args []string) {
+	mods, err := manager.LoadOrder()
+	if err != nil {
+		abort(err)
+	}
+	for _, mod := range mods {
+		fmt.Println(mod.ToString())
+	}
+}
+
+func pin(manager *fmm.Manager, args []string) {
+	if len(args) == 0 {
+		for _, pin := range manager.GetPins() {
+			fmt.Println(pin.ToString())
+		}
+		return
+	}
+
+	for _, input := range args {
+		dep, err := fmm.NewDependency(input)
+		if err != nil {
+			errorf("invalid pin %s\n", input)
+			errorln(err)
+			continue
+		}
+		if dep.Version == nil {
+			mod, err := manager.GetMod(dep.Name)
+			if err != nil {
+				errorf("failed to pin %s\n", dep.Name)
+				errorln(err)
+				continue
+			}
+			dep.Version = mod.Enabled
+			if dep.Version == nil {
+				dep.Version = &mod.GetLatestRelease().Version
+			}
+			dep.Req = fmm.VersionEq
+		}
+		manager.Pin(*dep)
+		fmt.Println("pinned", dep.ToString())
+	}
+}
+
+func publish(manager *fmm.Manager, args []string) {
+	if len(args) == 0 || len(args) > 2 {
+		printUsage("publish requires a mod zip file and an optional details file")
+	}
+	var details *fmm.ModDetails
+	if len(args) == 2 {
+		var err error
+		details, err = fmm.ParseModDetails(args[1])
 		if err != nil {
-			errorf("failed to add %s\n", mod.ToString())
+			abort(err)
+		}
+	}
+	if err := manager.Portal.PublishMod(args[0], details); err != nil {
+		abort(err)
+	}
+	fmt.Println("published", args[0])
+}
+
+func rdeps(manager *fmm.Manager, args []string) {
+	mods, _ := getMods(args)
+	for _, mod := range mods {
+		if _, err := manager.GetMod(mod.Name); err != nil {
+			errorf("%s: %s\n", mod.Name, err)
+			continue
+		}
+		dependents := manager.GetDependents(mod.Name)
+		if len(dependents) == 0 {
+			fmt.Println("no enabled mods depend on", mod.Name)
+			continue
+		}
+		fmt.Printf("%s:\n", mod.Name)
+		for _, req := range dependents {
+			fmt.Printf("  %s (%s %s)\n", req.Dependent.ToString(), req.Dependency.Kind.ToString(), req.Dependency.ConstraintString())
+		}
+	}
+}
+
+func remove(manager *fmm.Manager, args []string) {
+	mods, _ := getMods(args)
+	disabled := []string{}
+	for _, mod := range mods {
+		existing, _ := manager.GetMod(mod.Name)
+		wasEnabled := existing != nil && existing.Enabled != nil
+		removed, err := manager.Remove(mod)
+		for _, version := range removed {
+			fmt.Println("removed", mod.Name, version.ToString(false))
+		}
+		if err != nil {
+			errorf("failed to remove %s\n", mod.ToString())
 			errorln(err)
-		} else if ver != nil {
-			fmt.Println("enabled", mod.Name, ver.ToString(false))
 		}
+		if existing, _ := manager.GetMod(mod.Name); wasEnabled && (existing == nil || existing.Enabled == nil) {
+			disabled = append(disabled, mod.Name)
+		}
+	}
+
+	if dependents := manager.GetRequiringDependents(disabled); len(dependents) > 0 {
+		errorln("warning: the following enabled mods require a removed mod and will fail to load:")
+		for _, dependent := range dependents {
+			errorln(" ", dependent.ToString())
+		}
+	}
+}
+
+func search(manager *fmm.Manager, args []string) {
+	opts := fmm.SearchOptions{
+		Query:    strings.Join(args, " "),
+		Category: category,
+		Owner:    owner,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	if factorioVersion != "" {
+		ver, err := fmm.NewVersion(factorioVersion)
+		if err != nil {
+			abort(err)
+		}
+		opts.FactorioVersion = ver
+	}
+	result, err := manager.Portal.Search(opts)
+	if err != nil {
+		abort(err)
+	}
+	// Only print the names when piped, so that the results can be piped
+	// into other commands
+	piped := !term.IsTerminal(int(os.Stdout.Fd()))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, mod := range result.Mods {
+		if piped {
+			fmt.Fprintln(w, mod.Name)
+			continue
+		}
+		version := "-"
+		if mod.LatestRelease != nil {
+			version = mod.LatestRelease.Version.ToString(false)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d downloads\t%s\n", mod.Name, version, mod.DownloadsCount, mod.Title)
 	}
+	w.Flush()
+	// Written to stderr so that piping the results is not affected
+	errorf("page %d of %d (%d mods)\n", result.Page, max(result.PageCount, 1), result.Count)
+}
+
+func sync(manager *fmm.Manager, args []string) {
+	if locked {
+		syncLocked(manager, args)
+		return
+	}
+	manager.DisableAll()
+	fmt.Println("disabled all mods")
+	mods, settings := getMods(args)
+	enableResolved(manager, mods, true)
 	if settings != nil {
 		manager.MergeStartupModSettings(settings)
 		fmt.Println("synced startup mod settings")
 	}
 }
 
+func syncLocked(manager *fmm.Manager, args []string) {
+	path := manager.GetLockfilePath()
+	if len(args) > 0 {
+		path = args[0]
+	}
+	lockfile, err := fmm.ParseLockfile(path)
+	if err != nil {
+		abort(err)
+	}
+	if err := manager.SyncLocked(lockfile); err != nil {
+		abort(err)
+	}
+	fmt.Printf("synced %d mods from %s\n", len(lockfile.Mods), path)
+}
+
+func unpin(manager *fmm.Manager, args []string) {
+	mods, _ := getMods(args)
+	for _, mod := range mods {
+		if err := manager.Unpin(mod.Name); err != nil {
+			errorf("failed to unpin %s\n", mod.Name)
+			errorln(err)
+		} else {
+			fmt.Println("unpinned", mod.Name)
+		}
+	}
+}
+
+type updateChangelog struct {
+	Name      string        `json:"name"`
+	From      *fmm.Version  `json:"from"`
+	To        *fmm.Version  `json:"to"`
+	Changelog fmm.Changelog `json:"changelog"`
+}
+
 func update(manager *fmm.Manager, args []string) {
+	switch format {
+	case "", "text":
+	case "json":
+		// Keep stdout valid JSON
+		manager.SetDownloadProgressHandler(nil)
+		manager.SetStatusHandler(nil)
+	default:
+		abort("unrecognized update format", format)
+	}
 	mods, _ := getMods(args)
-	manager.CheckDownloadUpdates(mods)
+	updates, err := manager.GetUpdates(mods)
+	if err != nil {
+		errorln(err)
+	}
+	downloaded := map[string]bool{}
+	if !preview {
+		idents, err := manager.DownloadUpdates(updates)
+		if err != nil {
+			errorln(err)
+		}
+		for _, ident := range idents {
+			downloaded[ident.Name] = true
+		}
+	}
+
+	output := []updateChangelog{}
+	for _, update := range updates {
+		if update.HeldBack != nil {
+			ver := update.From
+			if update.Release != nil {
+				ver = &update.Release.Version
+			}
+			msg := fmt.Sprintf("%s held back at %s by pin (%s is available)", update.Name, ver.ToString(false), update.HeldBack.ToString(false))
+			if format == "json" {
+				errorln(msg)
+			} else {
+				fmt.Println(msg)
+			}
+		}
+		if update.Release == nil {
+			continue
+		}
+		var changelog fmm.Changelog
+		var err error
+		if preview {
+			changelog, err = manager.Portal.GetChangelog(update.Name)
+		} else if downloaded[update.Name] {
+			mod, _ := manager.GetMod(update.Name)
+			changelog, err = manager.GetChangelog(mod.GetRelease(&update.Release.Version))
+		} else {
+			continue
+		}
+		if err != nil {
+			errorf("failed to read changelog of %s: %s\n", update.Name, err)
+			changelog = fmm.Changelog{}
+		}
+		output = append(output, updateChangelog{
+			Name:      update.Name,
+			From:      update.From,
+			To:        &update.Release.Version,
+			Changelog: changelog.Between(update.From, &update.Release.Version),
+		})
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(output); err != nil {
+			abort(err)
+		}
+		return
+	}
+	for _, update := range output {
+		from := "none"
+		if update.From != nil {
+			from = update.From.ToString(false)
+		}
+		fmt.Printf("%s %s -> %s\n", update.Name, from, update.To.ToString(false))
+		fmt.Print(update.Changelog.ToString())
+	}
 }
 
 func upload(manager *fmm.Manager, files []string) {
+	failed := false
 	for _, file := range files {
 		if err := manager.Portal.UploadMod(file); err != nil {
-			fmt.Println(err)
+			errorf("failed to upload %s\n", file)
+			errorln(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func why(manager *fmm.Manager, args []string) {
+	mods, _ := getMods(args)
+	for _, mod := range mods {
+		chain, err := manager.Why(mod.Name)
+		if err != nil {
+			errorf("%s: %s\n", mod.Name, err)
+			continue
+		}
+		if len(chain) == 0 {
+			fmt.Println(mod.Name, "is not required by any enabled mod")
+			continue
+		}
+		for _, req := range chain {
+			fmt.Println(req.ToString())
 		}
 	}
 }
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raiguard/fmm/lib/portaltest"
+	"github.com/stretchr/testify/require"
+)
+
+// captureStdout runs fn and returns everything that it wrote to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-output
+}
+
+// newTestGame creates a game directory with the given local releases, and
+// points fmm at it and the given portal. Returns the mods directory.
+func newTestGame(t *testing.T, server *portaltest.Server, releases map[string]portaltest.Release) string {
+	gamePath := t.TempDir()
+	modsPath := filepath.Join(gamePath, "mods")
+	require.NoError(t, os.MkdirAll(filepath.Join(gamePath, "data", "base"), 0755))
+	require.NoError(t, os.Mkdir(modsPath, 0755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(gamePath, "data", "base", "info.json"),
+		[]byte(`{"name": "base", "version": "1.1.87", "dependencies": []}`),
+		0666,
+	))
+	for name, release := range releases {
+		filename := name + "_" + release.Version + ".zip"
+		require.NoError(t, os.WriteFile(filepath.Join(modsPath, filename), portaltest.ReleaseZip(name, release), 0666))
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "cache"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("FACTORIO_PATH", gamePath)
+	t.Setenv("FACTORIO_MODS_PATH", modsPath)
+	t.Setenv("FACTORIO_PORTAL_URL", server.URL)
+	t.Setenv("FACTORIO_USERNAME", server.Username)
+	t.Setenv("FACTORIO_TOKEN", server.Token)
+	stdin, err := os.Open(os.DevNull)
+	require.NoError(t, err)
+	oldStdin := os.Stdin
+	os.Stdin = stdin
+	t.Cleanup(func() {
+		os.Stdin = oldStdin
+		stdin.Close()
+	})
+	return modsPath
+}
+
+func TestUpdateJson(t *testing.T) {
+	server := portaltest.NewServer(portaltest.Mod{Name: "flib", Releases: []portaltest.Release{
+		{Version: "0.12.0", FactorioVersion: "1.1"},
+		{Version: "0.13.0", FactorioVersion: "1.1", Changelog: "---------------------------------------------------------------------------------------------------\nVersion: 0.13.0\n  Features:\n    - Added things\n"},
+	}})
+	t.Cleanup(server.Close)
+	modsPath := newTestGame(t, server, map[string]portaltest.Release{
+		"flib": {Version: "0.12.0", FactorioVersion: "1.1"},
+	})
+
+	output := captureStdout(t, func() {
+		Run([]string{"update", "--format", "json"})
+	})
+	var updates []updateChangelog
+	require.NoError(t, json.Unmarshal([]byte(output), &updates), output)
+	require.Len(t, updates, 1)
+	require.Equal(t, "flib", updates[0].Name)
+	require.Equal(t, "0.13.0", updates[0].To.ToString(false))
+	require.Len(t, updates[0].Changelog, 1)
+	require.FileExists(t, filepath.Join(modsPath, "flib_0.13.0.zip"))
+}
+
+func TestParsePipedArgs(t *testing.T) {
+	input := "flib 0.14.0\nbigmod\n\n  /saves/My Save.zip  \nname 1.0.0 extra\r\n"
+	require.Equal(t, []string{"flib_0.14.0", "bigmod", "/saves/My Save.zip", "name 1.0.0 extra"}, parsePipedArgs(input))
+}
+
+func TestExistingIncompatibility(t *testing.T) {
+	server := portaltest.NewServer()
+	t.Cleanup(server.Close)
+	modsPath := newTestGame(t, server, map[string]portaltest.Release{
+		"A": {Version: "1.0.0", FactorioVersion: "1.1", Dependencies: []string{"! B"}},
+		"B": {Version: "1.0.0", FactorioVersion: "1.1"},
+		"C": {Version: "1.0.0", FactorioVersion: "1.1"},
+	})
+	modList := `{"mods": [{"name": "base", "enabled": true}, {"name": "A", "enabled": true}, {"name": "B", "enabled": true}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(modsPath, "mod-list.json"), []byte(modList), 0666))
+
+	// A conflict that already existed does not stop unrelated mods from
+	// being enabled
+	captureStdout(t, func() {
+		Run([]string{"enable", "C"})
+	})
+	data, err := os.ReadFile(filepath.Join(modsPath, "mod-list.json"))
+	require.NoError(t, err)
+	var parsed struct {
+		Mods []struct {
+			Name    string `json:"name"`
+			Enabled bool   `json:"enabled"`
+		} `json:"mods"`
+	}
+	require.NoError(t, json.Unmarshal(data, &parsed))
+	enabled := []string{}
+	for _, mod := range parsed.Mods {
+		if mod.Enabled {
+			enabled = append(enabled, mod.Name)
+		}
+	}
+	require.ElementsMatch(t, []string{"base", "A", "B", "C"}, enabled)
+}
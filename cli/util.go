@@ -1,11 +1,15 @@
 package cli
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
+	"text/tabwriter"
 
 	fmm "github.com/raiguard/fmm/lib"
+	"golang.org/x/term"
 )
 
 func abort(msg ...any) {
@@ -29,6 +33,21 @@ func printUsage(msg ...any) {
 	os.Exit(1)
 }
 
+// parseFlags parses the given flags from anywhere in args and returns the
+// remaining positional arguments.
+func parseFlags(flags *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
 func getMods(args []string) ([]fmm.ModIdent, fmm.PropertyTree) {
 	var mods []fmm.ModIdent
 	var settings fmm.PropertyTree
@@ -67,3 +86,191 @@ func getMods(args []string) ([]fmm.ModIdent, fmm.PropertyTree) {
 
 	return mods, settings
 }
+
+// enableResolved resolves the dependencies of the given mods and enables the
+// result, downloading any missing releases if download is true. Nothing is
+// enabled if the dependencies cannot be resolved. Mods that are newly enabled
+// only as dependencies are marked as automatic.
+func enableResolved(manager *fmm.Manager, mods []fmm.ModIdent, download bool) {
+	resolved, err := manager.Resolve(mods, fmm.ResolveOptions{
+		FetchFromPortal: download,
+		Optional:        optional,
+		HiddenOptional:  hiddenOptional,
+	})
+	if err != nil {
+		abort(err)
+	}
+	if download {
+		if _, err := manager.DownloadMissing(resolved); err != nil {
+			errorln(err)
+		}
+	}
+	requested := map[string]bool{}
+	for _, mod := range mods {
+		requested[mod.Name] = true
+	}
+	for _, mod := range resolved {
+		wasEnabled := false
+		if existing, _ := manager.GetMod(mod.Name); existing != nil {
+			wasEnabled = existing.Enabled != nil
+		}
+		var ver *fmm.Version
+		if download {
+			ver, err = manager.Add(mod)
+		} else {
+			ver, err = manager.Enable(mod)
+		}
+		if err != nil {
+			errorf("failed to enable %s\n", mod.ToString())
+			errorln(err)
+			continue
+		}
+		if ver != nil {
+			fmt.Println("enabled", mod.Name, ver.ToString(false))
+		}
+		if heldBack := manager.GetHeldBack(mod); heldBack != nil {
+			fmt.Printf("%s held back at %s by pin (%s is available)\n", mod.Name, mod.Version.ToString(false), heldBack.ToString(false))
+		}
+		if requested[mod.Name] {
+			manager.SetAuto(mod.Name, false)
+		} else if !wasEnabled {
+			manager.SetAuto(mod.Name, true)
+		}
+	}
+}
+
+// printDownloadProgress prints a line when each download in a batch starts,
+// finishes or fails.
+func printDownloadProgress(event fmm.DownloadEvent) {
+	switch event.Kind {
+	case fmm.DownloadStarted:
+		fmt.Printf("downloading %s\n", event.FileName)
+	case fmm.DownloadFinished:
+		fmt.Printf("downloaded %s (%d/%d, %s)\n", event.FileName, event.FilesComplete, event.FilesTotal, formatBytes(event.TotalBytesComplete))
+	case fmm.DownloadFailed:
+		errorf("failed to download %s (%d/%d)\n", event.FileName, event.FilesComplete, event.FilesTotal)
+	}
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func printModInfo(manager *fmm.Manager, info *fmm.ModInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	field := func(name string, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", name, value)
+		}
+	}
+	field("name", info.Name)
+	if portal := info.Portal; portal != nil {
+		field("title", portal.Title)
+		field("owner", portal.Owner)
+		field("summary", portal.Summary)
+		field("homepage", portal.Homepage)
+		field("source", portal.SourceUrl)
+		if portal.License != nil {
+			field("license", portal.License.Title)
+		}
+	}
+
+	var dependencies []*fmm.Dependency
+	if local := info.Local; local != nil {
+		installed := []string{}
+		for _, release := range local.GetReleases() {
+			version := release.Version.ToString(false)
+			if local.Enabled != nil && release.Version.Cmp(local.Enabled) == fmm.VersionEq {
+				version += " (enabled)"
+			}
+			installed = append(installed, version)
+		}
+		field("installed", strings.Join(installed, ", "))
+		release := local.GetEnabledRelease()
+		if release == nil {
+			release = local.GetLatestRelease()
+		}
+		field("path", manager.GetReleasePath(release))
+		dependencies = release.Dependencies
+	} else {
+		field("installed", "no")
+		if portal := info.Portal; portal != nil && len(portal.Releases) > 0 {
+			dependencies = portal.Releases[len(portal.Releases)-1].InfoJson.Dependencies
+		}
+	}
+	if pin := manager.GetPin(info.Name); pin != nil {
+		field("pinned", pin.ToString())
+	}
+	if info.Update != nil {
+		field("update", info.Update.Version.ToString(false)+" is available")
+	}
+	if info.PortalErr != nil {
+		field("portal", info.PortalErr.Error())
+	}
+	w.Flush()
+
+	if len(dependencies) > 0 {
+		fmt.Println("dependencies:")
+		for _, dep := range dependencies {
+			fmt.Println("  " + dep.ToString())
+		}
+	}
+	if info.Portal != nil && len(info.Portal.Releases) > 0 {
+		fmt.Println("releases:")
+		for i := len(info.Portal.Releases) - 1; i >= 0; i-- {
+			release := &info.Portal.Releases[i]
+			fmt.Fprintf(w, "  %s\tfactorio %d.%d\n", release.Version.ToString(false), release.InfoJson.FactorioVersion[0], release.InfoJson.FactorioVersion[1])
+		}
+		w.Flush()
+	}
+}
+
+// prompt asks for a line of input on stderr and reads it from stdin. If secret
+// is true and stdin is a terminal, the input is not echoed.
+func prompt(label string, secret bool) string {
+	errorf("%s", label)
+	if fd := int(os.Stdin.Fd()); secret && term.IsTerminal(fd) {
+		line, err := term.ReadPassword(fd)
+		errorln()
+		if err != nil {
+			abort(err)
+		}
+		return string(line)
+	}
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		abort(err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// parsePipedArgs splits input that was piped into fmm into arguments, one per
+// line. Lines in the format of 'name version' are joined into 'name_version'
+// so that the output of list can be piped in.
+func parsePipedArgs(input string) []string {
+	args := []string{}
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) == 2 {
+			if _, err := fmm.NewVersion(fields[1]); err == nil {
+				line = fields[0] + "_" + fields[1]
+			}
+		}
+		args = append(args, line)
+	}
+	return args
+}
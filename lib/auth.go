@@ -0,0 +1,117 @@
+package fmm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+const DefaultAuthUrl = "https://auth.factorio.com"
+
+// Login exchanges the given username and password for a service token using
+// the Factorio authentication API. If the account requires a code that was sent
+// by email, ErrAuthCodeRequired is returned and the login must be repeated with
+// the code.
+func Login(authUrl string, username string, password string, code string) (PlayerData, error) {
+	loginUrl, err := url.JoinPath(authUrl, "api-login")
+	if err != nil {
+		return PlayerData{}, err
+	}
+	form := url.Values{
+		"username":    {username},
+		"password":    {password},
+		"api_version": {"6"},
+	}
+	if code != "" {
+		form.Set("email_authentication_code", code)
+	}
+	res, err := http.PostForm(loginUrl, form)
+	if err != nil {
+		return PlayerData{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		err := readPortalError(res)
+		var portalErr *PortalError
+		if errors.As(err, &portalErr) && portalErr.Code == "email-authentication-required" {
+			return PlayerData{}, ErrAuthCodeRequired
+		}
+		return PlayerData{}, err
+	}
+
+	var decoded struct {
+		Token    string `json:"token"`
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&decoded); err != nil {
+		return PlayerData{}, err
+	}
+	if decoded.Token == "" {
+		return PlayerData{}, errors.New("the authentication server did not return a token")
+	}
+	if decoded.Username == "" {
+		decoded.Username = username
+	}
+	return PlayerData{Token: decoded.Token, Username: decoded.Username}, nil
+}
+
+// GetCredentialsPath returns the path of the file that fmm stores the player
+// data obtained by Login in.
+func GetCredentialsPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "fmm", "credentials.json"), nil
+}
+
+type credentialsJson struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
+// SaveCredentials writes the given player data to the credentials file. The
+// file is only readable by the current user.
+func SaveCredentials(playerData PlayerData) error {
+	path, err := GetCredentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(credentialsJson{playerData.Username, playerData.Token}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// WriteFile does not change the permissions of an existing file
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
+
+// readCredentials reads the player data from the credentials file if the game
+// directory did not provide any.
+func (m *Manager) readCredentials() error {
+	if m.HasPlayerData() {
+		return nil
+	}
+	path, err := GetCredentialsPath()
+	if err != nil || !entryExists(path) {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Join(errors.New("unable to read credentials"), err)
+	}
+	var credentials credentialsJson
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return errors.Join(errors.New("invalid credentials format"), err)
+	}
+	m.Portal.playerData = PlayerData{Token: credentials.Token, Username: credentials.Username}
+	return nil
+}
@@ -0,0 +1,169 @@
+package fmm
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A Changelog is a parsed changelog.txt file, with the newest version first.
+type Changelog []ChangelogVersion
+
+type ChangelogVersion struct {
+	Version    Version             `json:"version"`
+	Date       string              `json:"date,omitempty"`
+	Categories []ChangelogCategory `json:"categories"`
+}
+
+type ChangelogCategory struct {
+	Name string `json:"name"`
+	// Entries that span multiple lines are joined with newlines.
+	Entries []string `json:"entries"`
+}
+
+const changelogSeparator = "---------------------------------------------------------------------------------------------------"
+
+// ParseChangelog parses a changelog in the format that Factorio uses for
+// changelog.txt files.
+func ParseChangelog(r io.Reader) (Changelog, error) {
+	changelog := Changelog{}
+	var version *ChangelogVersion
+	var category *ChangelogCategory
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		indent := len(line) - len(trimmed)
+		fail := func(msg string) (Changelog, error) {
+			return nil, fmt.Errorf("line %d: %s", lineNum, msg)
+		}
+
+		switch {
+		case trimmed == "":
+			continue
+		case strings.Trim(line, "-") == "":
+			version, category = nil, nil
+		case indent == 0 && strings.HasPrefix(line, "Version:"):
+			ver, err := NewVersion(strings.TrimSpace(strings.TrimPrefix(line, "Version:")))
+			if err != nil {
+				return fail(err.Error())
+			}
+			changelog = append(changelog, ChangelogVersion{Version: *ver, Categories: []ChangelogCategory{}})
+			version, category = &changelog[len(changelog)-1], nil
+		case indent == 0 && strings.HasPrefix(line, "Date:"):
+			if version == nil {
+				return fail("date outside of a version")
+			}
+			version.Date = strings.TrimSpace(strings.TrimPrefix(line, "Date:"))
+		case indent > 0 && strings.HasPrefix(trimmed, "- "):
+			if category == nil {
+				return fail("entry outside of a category")
+			}
+			category.Entries = append(category.Entries, strings.TrimPrefix(trimmed, "- "))
+		case indent > 0 && indent < 4 && strings.HasSuffix(trimmed, ":"):
+			if version == nil {
+				return fail("category outside of a version")
+			}
+			version.Categories = append(version.Categories, ChangelogCategory{Name: strings.TrimSuffix(trimmed, ":"), Entries: []string{}})
+			category = &version.Categories[len(version.Categories)-1]
+		case indent > 0 && category != nil && len(category.Entries) > 0:
+			// Continuation of the previous entry
+			category.Entries[len(category.Entries)-1] += "\n" + trimmed
+		default:
+			return fail(fmt.Sprintf("unexpected line '%s'", trimmed))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return changelog, nil
+}
+
+// Between returns the versions that are newer than from and not newer than
+// to, newest first. If from is nil, every version up to to is returned.
+func (c Changelog) Between(from *Version, to *Version) Changelog {
+	output := Changelog{}
+	for _, version := range c {
+		if from != nil && version.Version.Cmp(from) != VersionGt {
+			continue
+		}
+		if version.Version.Cmp(to) == VersionGt {
+			continue
+		}
+		output = append(output, version)
+	}
+	return output
+}
+
+// ToString formats the changelog in the changelog.txt format.
+func (c Changelog) ToString() string {
+	var b strings.Builder
+	for _, version := range c {
+		b.WriteString(changelogSeparator + "\n")
+		b.WriteString("Version: " + version.Version.ToString(false) + "\n")
+		if version.Date != "" {
+			b.WriteString("Date: " + version.Date + "\n")
+		}
+		for _, category := range version.Categories {
+			b.WriteString("  " + category.Name + ":\n")
+			for _, entry := range category.Entries {
+				b.WriteString("    - " + strings.ReplaceAll(entry, "\n", "\n      ") + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// GetChangelog reads the changelog of the given local release. Returns an
+// empty changelog if the release does not have one.
+func (m *Manager) GetChangelog(release *Release) (Changelog, error) {
+	releasePath := m.GetReleasePath(release)
+	info, err := os.Stat(releasePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() || isSymlink(info) {
+		file, err := os.Open(filepath.Join(releasePath, "changelog.txt"))
+		if errors.Is(err, os.ErrNotExist) {
+			return Changelog{}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return ParseChangelog(file)
+	}
+
+	r, err := zip.OpenReader(releasePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for _, file := range r.File {
+		parts := strings.Split(file.Name, "/")
+		if len(parts) != 2 || parts[1] != "changelog.txt" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return ParseChangelog(rc)
+	}
+	return Changelog{}, nil
+}
+
+// GetChangelog fetches the changelog of the newest release of the given mod
+// from the mod portal.
+func (p *ModPortal) GetChangelog(name string) (Changelog, error) {
+	mod, err := p.GetModInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseChangelog(strings.NewReader(mod.Changelog))
+}
@@ -0,0 +1,53 @@
+package fmm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testChangelog = `---------------------------------------------------------------------------------------------------
+Version: 0.14.0
+Date: 2023-03-01
+  Features:
+    - Added a thing
+      that spans two lines
+  Bugfixes:
+    - Fixed a crash
+---------------------------------------------------------------------------------------------------
+Version: 0.13.0
+Date: 2023-02-01
+  Changes:
+    - Changed something
+---------------------------------------------------------------------------------------------------
+Version: 0.12.0
+  Features:
+    - Initial release
+`
+
+func TestParseChangelog(t *testing.T) {
+	changelog, err := ParseChangelog(strings.NewReader(testChangelog))
+	require.NoError(t, err)
+	require.Len(t, changelog, 3)
+	require.Equal(t, ChangelogVersion{
+		Version: Version{0, 14},
+		Date:    "2023-03-01",
+		Categories: []ChangelogCategory{
+			{Name: "Features", Entries: []string{"Added a thing\nthat spans two lines"}},
+			{Name: "Bugfixes", Entries: []string{"Fixed a crash"}},
+		},
+	}, changelog[0])
+	require.Equal(t, "", changelog[2].Date)
+	require.Equal(t, testChangelog, changelog.ToString())
+
+	between := changelog.Between(&Version{0, 12}, &Version{0, 13})
+	require.Len(t, between, 1)
+	require.Equal(t, Version{0, 13}, between[0].Version)
+	require.Len(t, changelog.Between(nil, &Version{0, 14}), 3)
+
+	_, err = ParseChangelog(strings.NewReader("Version: 1.0.0\n    - Entry without a category\n"))
+	require.ErrorContains(t, err, "line 2")
+	_, err = ParseChangelog(strings.NewReader("Version: invalid\n"))
+	require.Error(t, err)
+}
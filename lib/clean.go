@@ -0,0 +1,86 @@
+package fmm
+
+import (
+	"cmp"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+)
+
+// The number of releases of each mod that Clean keeps by default, in addition
+// to the enabled release.
+const DefaultCleanKeep = 1
+
+type CleanedRelease struct {
+	Name    string
+	Version Version
+	Path    string
+	// The size of the release's zip file in bytes.
+	Size int64
+}
+
+// Clean deletes the zip files of every release except the enabled release and
+// the newest keep releases of each mod. Releases that are listed in the
+// lockfile at the default path in the mods directory, and the newest release
+// that satisfies each pin, are never deleted. Unzipped and symlinked releases are left alone. If dryRun is true,
+// nothing is deleted. Returns the releases that were, or would be, deleted.
+func (m *Manager) Clean(keep int, dryRun bool) ([]CleanedRelease, error) {
+	if keep < 0 {
+		return nil, errors.New("the number of releases to keep cannot be negative")
+	}
+	locked := map[string]bool{}
+	if entryExists(m.GetLockfilePath()) {
+		lockfile, err := ParseLockfile(m.GetLockfilePath())
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range lockfile.Mods {
+			if entry.Version != nil {
+				locked[entry.Name+"_"+entry.Version.ToString(false)] = true
+			}
+		}
+	}
+
+	toClean := []*Release{}
+	for _, mod := range m.mods {
+		if mod.isInternal {
+			continue
+		}
+		var pinned *Release
+		if pin := m.pins[mod.Name]; pin != nil {
+			pinned = mod.GetMatchingRelease(pin)
+		}
+		for i, release := range mod.releases {
+			if i >= len(mod.releases)-keep ||
+				release == pinned ||
+				(mod.Enabled != nil && release.Version == *mod.Enabled) ||
+				locked[release.Name+"_"+release.Version.ToString(false)] ||
+				!strings.HasSuffix(release.Path, ".zip") {
+				continue
+			}
+			toClean = append(toClean, release)
+		}
+	}
+	// Releases are already sorted by version within each mod
+	slices.SortStableFunc(toClean, func(a, b *Release) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	output := []CleanedRelease{}
+	var errs []error
+	for _, release := range toClean {
+		cleaned := CleanedRelease{Name: release.Name, Version: release.Version, Path: m.GetReleasePath(release)}
+		if info, err := os.Lstat(cleaned.Path); err == nil {
+			cleaned.Size = info.Size()
+		}
+		if !dryRun {
+			if err := m.removeRelease(m.mods[release.Name], release); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		output = append(output, cleaned)
+	}
+	return output, errors.Join(errs...)
+}
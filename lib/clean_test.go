@@ -0,0 +1,67 @@
+package fmm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClean(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"base_1.1.0"},
+		[]string{"flib_0.11.0"},
+		[]string{"flib_0.12.0"},
+		[]string{"flib_0.13.0"},
+		[]string{"flib_0.14.0"},
+		[]string{"locked_1.0.0"},
+		[]string{"locked_2.0.0"},
+		[]string{"pinned_1.0.0"},
+		[]string{"pinned_2.0.0"},
+		[]string{"pinned_3.0.0"},
+	)
+	m.modsPath = t.TempDir()
+	for _, mod := range m.mods {
+		for _, release := range mod.releases {
+			if !mod.isInternal {
+				require.NoError(t, os.WriteFile(filepath.Join(m.modsPath, release.Path), []byte("zip"), 0666))
+			}
+		}
+	}
+	_, err := m.Enable(ModIdent{Name: "flib", Version: &Version{0, 12}})
+	require.NoError(t, err)
+	m.Pin(Dependency{Name: "pinned", Version: &Version{2}, Req: VersionLt})
+	lockfile := Lockfile{Mods: []LockfileMod{{Name: "locked", Version: &Version{1}}}}
+	require.NoError(t, lockfile.Write(m.GetLockfilePath()))
+
+	paths := func(cleaned []CleanedRelease) []string {
+		output := []string{}
+		for _, release := range cleaned {
+			output = append(output, filepath.Base(release.Path))
+		}
+		return output
+	}
+
+	_, err = m.Clean(-1, true)
+	require.Error(t, err)
+
+	cleaned, err := m.Clean(1, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"flib_0.11.0.zip", "flib_0.13.0.zip", "pinned_2.0.0.zip"}, paths(cleaned))
+	require.Equal(t, int64(3), cleaned[0].Size)
+	require.FileExists(t, filepath.Join(m.modsPath, "flib_0.11.0.zip"))
+
+	cleaned, err = m.Clean(2, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"flib_0.11.0.zip"}, paths(cleaned))
+	require.NoFileExists(t, filepath.Join(m.modsPath, "flib_0.11.0.zip"))
+	require.Nil(t, m.mods["flib"].GetRelease(&Version{0, 11}))
+
+	cleaned, err = m.Clean(0, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"flib_0.13.0.zip", "flib_0.14.0.zip", "locked_2.0.0.zip", "pinned_2.0.0.zip", "pinned_3.0.0.zip"}, paths(cleaned))
+	require.FileExists(t, filepath.Join(m.modsPath, "flib_0.12.0.zip"))
+	require.FileExists(t, filepath.Join(m.modsPath, "locked_1.0.0.zip"))
+	require.FileExists(t, filepath.Join(m.modsPath, "pinned_1.0.0.zip"))
+}
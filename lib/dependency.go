@@ -30,6 +30,22 @@ var dependencyKindString = map[DependencyKind]string{
 	DependencyNoLoadOrder:    "~ ",
 }
 
+var dependencyKindName = map[DependencyKind]string{
+	DependencyRequired:       "required",
+	DependencyOptional:       "optional",
+	DependencyHiddenOptional: "hidden optional",
+	DependencyIncompatible:   "incompatible",
+	DependencyNoLoadOrder:    "no load order",
+}
+
+func (k DependencyKind) ToString() string {
+	return dependencyKindName[k]
+}
+
+func (k DependencyKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.ToString())
+}
+
 func NewDependency(input string) (*Dependency, error) {
 	input = strings.TrimSpace(input)
 
@@ -48,14 +64,24 @@ func NewDependency(input string) (*Dependency, error) {
 		input = strings.TrimPrefix(input, "~")
 	}
 
-	// Iterate in reverse and find the first non-digit and non-dot
+	// Iterate in reverse and find the first non-digit and non-dot. The version
+	// may be separated from the name or operator by a space, or directly follow
+	// the operator.
 	var ver *Version
 	for i := len(input) - 1; i >= 0; i-- {
 		if i > 0 && !(input[i] == '.' || (input[i] >= '0' && input[i] <= '9')) {
-			parsed, err := NewVersion(input[i:])
+			isOperator := strings.ContainsRune("<>=", rune(input[i]))
+			if !isOperator && input[i] != ' ' {
+				break
+			}
+			parsed, err := NewVersion(input[i+1:])
 			if err == nil {
 				ver = parsed
-				input = strings.TrimSpace(input[:i])
+				if isOperator {
+					input = strings.TrimSpace(input[:i+1])
+				} else {
+					input = strings.TrimSpace(input[:i])
+				}
 			}
 			break
 		}
@@ -100,12 +126,42 @@ func (d *Dependency) Test(ver *Version) bool {
 	return d.Req&ver.Cmp(d.Version) > 0
 }
 
+// isRequired returns true if the dependency must be present for the
+// dependent mod to load.
+func (d *Dependency) isRequired() bool {
+	return d.Kind == DependencyRequired || d.Kind == DependencyNoLoadOrder
+}
+
+// excludes returns true if the dependency is incompatible with the given
+// version of its mod.
+func (d *Dependency) excludes(ver *Version) bool {
+	if d.Kind != DependencyIncompatible {
+		return false
+	}
+	if ver == nil || d.Version == nil || d.Req == VersionAny {
+		return true
+	}
+	return d.Req&ver.Cmp(d.Version) > 0
+}
+
+// ConstraintString returns the version constraint of the dependency in the
+// format of '>= 1.2.3', or 'any' if there is no constraint.
+func (d *Dependency) ConstraintString() string {
+	if d.Req == VersionAny || d.Version == nil {
+		return "any"
+	}
+	return versionCmpResString[d.Req] + " " + d.Version.ToString(false)
+}
+
 func (d *Dependency) ToString() string {
-	versionStr := ""
-	if d.Version != nil {
-		versionStr = d.Version.ToString(false)
+	if d.Req == VersionAny || d.Version == nil {
+		return dependencyKindString[d.Kind] + d.Name
 	}
-	return fmt.Sprintf("%s%s %s %s", dependencyKindString[d.Kind], d.Name, versionCmpResString[d.Req], versionStr)
+	return fmt.Sprintf("%s%s %s %s", dependencyKindString[d.Kind], d.Name, versionCmpResString[d.Req], d.Version.ToString(false))
+}
+
+func (d *Dependency) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.ToString())
 }
 
 func (d *Dependency) UnmarshalJSON(data []byte) error {
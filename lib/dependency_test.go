@@ -15,6 +15,10 @@ func TestNewDependency(t *testing.T) {
 		req     VersionCmpRes
 	}{
 		{"flib", "flib", nil, DependencyRequired, VersionAny},
+		{"? flib >= 0.10", "flib", &Version{0, 10}, DependencyOptional, VersionGtEq},
+		{"flib=1.2.3", "flib", &Version{1, 2, 3}, DependencyRequired, VersionEq},
+		{"flib<0.14", "flib", &Version{0, 14}, DependencyRequired, VersionLt},
+		{"mod2.0", "mod2.0", nil, DependencyRequired, VersionAny},
 	}
 	for _, test := range tests {
 		dep, err := NewDependency(test.input)
@@ -26,7 +30,7 @@ func TestNewDependency(t *testing.T) {
 			require.Nil(t, dep.Version)
 		} else {
 			require.NotNil(t, dep.Version)
-			require.Equal(t, dep.Version.Cmp(test.version), test.req)
+			require.Equal(t, dep.Version.Cmp(test.version), VersionEq)
 		}
 
 		require.Equal(t, dep.Kind, test.kind)
@@ -55,3 +59,20 @@ func TestDependencyTest(t *testing.T) {
 		require.Equal(t, dep.Test(&test.version), test.result)
 	}
 }
+
+func TestDependencyToString(t *testing.T) {
+	tests := []struct {
+		input, output string
+	}{
+		{"flib", "flib"},
+		{"? flib >= 0.10", "? flib >= 0.10.0"},
+		{"(?) flib", "(?) flib"},
+		{"~ flib = 1.0.1", "~ flib = 1.0.1"},
+		{"! flib", "! flib"},
+	}
+	for _, test := range tests {
+		dep, err := NewDependency(test.input)
+		require.NoError(t, err)
+		require.Equal(t, test.output, dep.ToString())
+	}
+}
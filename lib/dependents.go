@@ -0,0 +1,114 @@
+package fmm
+
+import (
+	"cmp"
+	"slices"
+)
+
+// GetDependents returns a Requirement for every dependency that an enabled
+// release declares on the given mod. Incompatibilities are not included.
+func (m *Manager) GetDependents(name string) []Requirement {
+	return m.reverseDependencies()[name]
+}
+
+// GetRequiringDependents returns every enabled mod that transitively requires
+// one of the given mods, not including the given mods themselves. These mods
+// will fail to load if the given mods are disabled.
+func (m *Manager) GetRequiringDependents(names []string) []ModIdent {
+	reverse := m.reverseDependencies()
+	visited := map[string]bool{}
+	for _, name := range names {
+		visited[name] = true
+	}
+	queue := slices.Clone(names)
+	output := []ModIdent{}
+	for i := 0; i < len(queue); i++ {
+		for _, req := range reverse[queue[i]] {
+			dependent := req.Dependent.Name
+			if !req.Dependency.isRequired() || visited[dependent] {
+				continue
+			}
+			visited[dependent] = true
+			queue = append(queue, dependent)
+			output = append(output, *req.Dependent)
+		}
+	}
+	slices.SortFunc(output, func(a, b ModIdent) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return output
+}
+
+// Why explains why the given mod is enabled. Returns the shortest chain of
+// required dependencies that leads from an enabled mod that nothing else
+// requires to the given mod, or nil if nothing requires the given mod.
+func (m *Manager) Why(name string) ([]Requirement, error) {
+	mod, err := m.GetMod(name)
+	if err != nil {
+		return nil, err
+	}
+	if mod.Enabled == nil {
+		return nil, ErrModNotEnabled
+	}
+
+	reverse := m.reverseDependencies()
+	// Breadth-first search towards the dependents, remembering the edge that
+	// each mod was reached from
+	via := map[string]Requirement{}
+	queue := []string{name}
+	for i := 0; i < len(queue); i++ {
+		current := queue[i]
+		isRoot := true
+		for _, req := range reverse[current] {
+			if !req.Dependency.isRequired() {
+				continue
+			}
+			isRoot = false
+			dependent := req.Dependent.Name
+			if _, ok := via[dependent]; ok || dependent == name {
+				continue
+			}
+			via[dependent] = req
+			queue = append(queue, dependent)
+		}
+		if !isRoot {
+			continue
+		}
+		chain := []Requirement{}
+		for current != name {
+			req := via[current]
+			chain = append(chain, req)
+			current = req.Dependency.Name
+		}
+		if len(chain) == 0 {
+			return nil, nil
+		}
+		return chain, nil
+	}
+
+	// Every dependent is part of a cycle, so there is no root to start from
+	return nil, nil
+}
+
+func (m *Manager) reverseDependencies() map[string][]Requirement {
+	reverse := map[string][]Requirement{}
+	for _, mod := range m.mods {
+		release := mod.GetEnabledRelease()
+		if release == nil {
+			continue
+		}
+		ident := ModIdent{mod.Name, &release.Version}
+		for _, dep := range release.Dependencies {
+			if dep.Kind == DependencyIncompatible {
+				continue
+			}
+			reverse[dep.Name] = append(reverse[dep.Name], Requirement{Dependent: &ident, Dependency: *dep})
+		}
+	}
+	for _, reqs := range reverse {
+		slices.SortFunc(reqs, func(a, b Requirement) int {
+			return cmp.Compare(a.Dependent.Name, b.Dependent.Name)
+		})
+	}
+	return reverse
+}
@@ -0,0 +1,64 @@
+package fmm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependents(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"bigmod_1.0.0", "mid >= 1.0", "? flib"},
+		[]string{"mid_1.0.0", "flib >= 0.12"},
+		[]string{"other_1.0.0", "! flib"},
+		[]string{"flib_0.12.0"},
+		[]string{"unused_1.0.0", "flib"},
+	)
+	for _, name := range []string{"bigmod", "mid", "other", "flib"} {
+		_, err := m.Enable(ModIdent{Name: name})
+		require.NoError(t, err)
+	}
+
+	dependents := m.GetDependents("flib")
+	require.Len(t, dependents, 2)
+	require.Equal(t, "bigmod", dependents[0].Dependent.Name)
+	require.Equal(t, DependencyOptional, dependents[0].Dependency.Kind)
+	require.Equal(t, "mid", dependents[1].Dependent.Name)
+	require.Equal(t, ">= 0.12.0", dependents[1].Dependency.ConstraintString())
+
+	chain, err := m.Why("flib")
+	require.NoError(t, err)
+	require.Len(t, chain, 2)
+	require.Equal(t, "bigmod 1.0.0 requires mid >= 1.0.0", chain[0].ToString())
+	require.Equal(t, "mid 1.0.0 requires flib >= 0.12.0", chain[1].ToString())
+
+	chain, err = m.Why("bigmod")
+	require.NoError(t, err)
+	require.Empty(t, chain)
+
+	_, err = m.Why("unused")
+	require.ErrorIs(t, err, ErrModNotEnabled)
+}
+
+func TestGetRequiringDependents(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"bigmod_1.0.0", "mid"},
+		[]string{"mid_1.0.0", "~ flib"},
+		[]string{"optional_1.0.0", "? flib"},
+		[]string{"other_1.0.0", "flib"},
+		[]string{"flib_1.0.0"},
+		[]string{"disabled_1.0.0", "flib"},
+	)
+	for _, name := range []string{"bigmod", "mid", "optional", "other", "flib"} {
+		_, err := m.Enable(ModIdent{Name: name})
+		require.NoError(t, err)
+	}
+
+	dependents := m.GetRequiringDependents([]string{"flib"})
+	names := []string{}
+	for _, mod := range dependents {
+		names = append(names, mod.Name)
+	}
+	require.Equal(t, []string{"bigmod", "mid", "other"}, names)
+	require.Empty(t, m.GetRequiringDependents([]string{"bigmod"}))
+}
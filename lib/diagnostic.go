@@ -0,0 +1,20 @@
+package fmm
+
+import "strings"
+
+// A Diagnostic is a problem with a file in the mods directory that caused it to
+// be skipped.
+type Diagnostic struct {
+	Path string
+	Err  error
+}
+
+func (d *Diagnostic) ToString() string {
+	return d.Path + ": " + strings.ReplaceAll(d.Err.Error(), "\n", ": ")
+}
+
+// GetDiagnostics returns the problems that were found when reading the mods
+// directory.
+func (m *Manager) GetDiagnostics() []Diagnostic {
+	return m.diagnostics
+}
@@ -0,0 +1,245 @@
+package fmm
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cavaliergopher/grab/v3"
+)
+
+// The number of releases that are downloaded at the same time by default.
+const DefaultDownloadParallelism = 4
+
+// Releases are downloaded to a file with this suffix, and only moved to their
+// real path once their hash has been verified.
+const downloadTempSuffix = ".part"
+
+// How often DownloadProgress events are sent for each file.
+const downloadProgressInterval = 200 * time.Millisecond
+
+type DownloadEventKind uint8
+
+const (
+	DownloadStarted DownloadEventKind = iota
+	DownloadProgress
+	DownloadFinished
+	DownloadFailed
+)
+
+// A DownloadEvent reports the progress of a single file and of the batch of
+// downloads that it is a part of.
+type DownloadEvent struct {
+	Kind     DownloadEventKind
+	FileName string
+	// The number of bytes of this file that have been downloaded, and its
+	// total size. Size is -1 if it is not known yet.
+	BytesComplete int64
+	Size          int64
+	// Set if Kind is DownloadFailed.
+	Err error
+
+	// The number of files in the batch that have finished or failed, and the
+	// total number of files in the batch.
+	FilesComplete int
+	FilesTotal    int
+	// The number of bytes that have been downloaded across the whole batch.
+	TotalBytesComplete int64
+}
+
+// downloadBatch serializes the progress events of concurrent downloads.
+type downloadBatch struct {
+	mu            sync.Mutex
+	handler       func(DownloadEvent)
+	filesComplete int
+	filesTotal    int
+	bytes         map[string]int64
+}
+
+func (b *downloadBatch) emit(event DownloadEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bytes[event.FileName] = event.BytesComplete
+	if event.Kind == DownloadFinished || event.Kind == DownloadFailed {
+		b.filesComplete++
+	}
+	if b.handler == nil {
+		return
+	}
+	event.FilesComplete = b.filesComplete
+	event.FilesTotal = b.filesTotal
+	for _, bytes := range b.bytes {
+		event.TotalBytesComplete += bytes
+	}
+	b.handler(event)
+}
+
+// downloadReleases downloads the given releases into the download path using a
+// pool of workers. Returns the path of each downloaded release, or the error
+// that prevented it from being downloaded, in the same order as the input.
+func (p *ModPortal) downloadReleases(releases []*PortalModRelease) ([]string, []error) {
+	paths := make([]string, len(releases))
+	errs := make([]error, len(releases))
+	batch := downloadBatch{handler: p.progress, filesTotal: len(releases), bytes: map[string]int64{}}
+
+	workers := p.parallelism
+	if workers < 1 {
+		workers = DefaultDownloadParallelism
+	}
+	workers = min(workers, len(releases))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each job writes to a distinct index, so no locking is needed
+			for i := range jobs {
+				paths[i], errs[i] = p.downloadFile(releases[i], &batch)
+			}
+		}()
+	}
+	for i := range releases {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return paths, errs
+}
+
+func (p *ModPortal) downloadFile(release *PortalModRelease, batch *downloadBatch) (string, error) {
+	fail := func(err error) (string, error) {
+		batch.emit(DownloadEvent{Kind: DownloadFailed, FileName: release.FileName, Err: err})
+		return "", err
+	}
+
+	if p.offline {
+		return fail(ErrPortalOffline)
+	}
+	if p.playerData.Token == "" {
+		return fail(errors.New("token was not specified"))
+	}
+	if p.playerData.Username == "" {
+		return fail(errors.New("username was not specified"))
+	}
+
+	downloadUrl, err := url.JoinPath(p.server, release.DownloadUrl)
+	if err != nil {
+		return fail(err)
+	}
+	downloadUrl += "?" + url.Values{
+		"username": {p.playerData.Username},
+		"token":    {p.playerData.Token},
+	}.Encode()
+	outPath := filepath.Join(p.downloadPath, release.FileName)
+	tempPath := outPath + downloadTempSuffix
+
+	req, err := grab.NewRequest(tempPath, downloadUrl)
+	if err != nil {
+		return fail(err)
+	}
+	// A leftover partial file may be corrupted, so always start from scratch
+	req.NoResume = true
+	batch.emit(DownloadEvent{Kind: DownloadStarted, FileName: release.FileName, Size: -1})
+	res := grab.DefaultClient.Do(req)
+
+	ticker := time.NewTicker(downloadProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			batch.emit(DownloadEvent{
+				Kind:          DownloadProgress,
+				FileName:      release.FileName,
+				BytesComplete: res.BytesComplete(),
+				Size:          res.Size(),
+			})
+			continue
+		case <-res.Done:
+		}
+		break
+	}
+
+	if err := res.Err(); err != nil {
+		os.Remove(tempPath)
+		return fail(fmt.Errorf("failed to download %s: %w", release.FileName, err))
+	}
+	if release.Sha1 != "" {
+		hash, err := fileSha1(tempPath)
+		if err == nil && hash != release.Sha1 {
+			err = fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, release.Sha1, hash)
+		}
+		if err != nil {
+			os.Remove(tempPath)
+			return fail(fmt.Errorf("failed to verify %s: %w", release.FileName, err))
+		}
+	}
+	if err := os.Rename(tempPath, outPath); err != nil {
+		os.Remove(tempPath)
+		return fail(err)
+	}
+	batch.emit(DownloadEvent{
+		Kind:          DownloadFinished,
+		FileName:      release.FileName,
+		BytesComplete: res.BytesComplete(),
+		Size:          res.Size(),
+	})
+	return outPath, nil
+}
+
+// DownloadMissing downloads every given release that does not exist locally
+// from the mod portal, several at a time, and adds them to the mods directory.
+// If a mod has no version, its newest compatible release is downloaded if it
+// has no local releases. Returns the mods that were downloaded.
+func (m *Manager) DownloadMissing(mods []ModIdent) ([]ModIdent, error) {
+	var errs []error
+	releases := []*PortalModRelease{}
+	for _, ident := range mods {
+		if mod := m.mods[ident.Name]; mod != nil && (ident.Version == nil || mod.GetRelease(ident.Version) != nil) {
+			continue
+		}
+		dep := Dependency{Name: ident.Name, Version: ident.Version, Kind: DependencyRequired, Req: VersionAny}
+		if ident.Version != nil {
+			dep.Req = VersionEq
+		}
+		release, err := m.Portal.GetMatchingRelease(&dep)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ident.ToString(), err))
+			continue
+		}
+		releases = append(releases, release)
+	}
+
+	downloaded, err := m.downloadReleases(releases)
+	return downloaded, errors.Join(append(errs, err)...)
+}
+
+// downloadReleases downloads the given releases in parallel and adds them to
+// the mods directory. The mods map is only modified on the calling goroutine
+// once all downloads are complete.
+func (m *Manager) downloadReleases(releases []*PortalModRelease) ([]ModIdent, error) {
+	if len(releases) == 0 {
+		return []ModIdent{}, nil
+	}
+	paths, errs := m.Portal.downloadReleases(releases)
+	downloaded := []ModIdent{}
+	for i, path := range paths {
+		if errs[i] != nil {
+			continue
+		}
+		release, err := releaseFromFile(path)
+		if err != nil {
+			errs[i] = errors.Join(fmt.Errorf("invalid mod %s", releases[i].FileName), err)
+			continue
+		}
+		m.addRelease(release, false)
+		downloaded = append(downloaded, ModIdent{release.Name, &release.Version})
+	}
+	return downloaded, errors.Join(errs...)
+}
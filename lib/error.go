@@ -3,9 +3,15 @@ package fmm
 import "errors"
 
 var (
+	ErrAuthCodeRequired     = errors.New("an authentication code was sent by email")
+	ErrChecksumMismatch     = errors.New("checksum mismatch")
 	ErrInvalidGameDirectory = errors.New("invalid game directory")
 	ErrModAlreadyDisabled   = errors.New("mod is already disabled")
 	ErrModAlreadyEnabled    = errors.New("mod is already enabled")
+	ErrModIsInternal        = errors.New("mod is internal")
+	ErrModNotEnabled        = errors.New("mod is not enabled")
 	ErrModNotFoundLocal     = errors.New("mod was not found in the local mods directory")
+	ErrModNotPinned         = errors.New("mod is not pinned")
 	ErrNoCompatibleRelease  = errors.New("no compatible release was found")
+	ErrPortalOffline        = errors.New("the mod portal is unavailable in offline mode")
 )
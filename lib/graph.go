@@ -0,0 +1,206 @@
+package fmm
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// A Graph is the dependency graph of a set of mod releases.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+	Name string `json:"name"`
+	// Nil if the mod is missing.
+	Version *Version        `json:"version,omitempty"`
+	Status  GraphNodeStatus `json:"status"`
+}
+
+type GraphNodeStatus uint8
+
+const (
+	GraphNodeOk GraphNodeStatus = iota
+	// No local release of the mod exists.
+	GraphNodeMissing
+	// The release does not satisfy at least one of its dependents.
+	GraphNodeUnsatisfied
+)
+
+var graphNodeStatusString = map[GraphNodeStatus]string{
+	GraphNodeOk:          "ok",
+	GraphNodeMissing:     "missing",
+	GraphNodeUnsatisfied: "unsatisfied",
+}
+
+func (s GraphNodeStatus) ToString() string {
+	return graphNodeStatusString[s]
+}
+
+func (s GraphNodeStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.ToString())
+}
+
+type GraphEdge struct {
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	Kind       DependencyKind `json:"kind"`
+	Constraint string         `json:"constraint"`
+	// False if the target release violates the dependency.
+	Satisfied bool `json:"satisfied"`
+}
+
+// Graph walks the dependencies of the given mods and returns the resulting
+// graph. If no mods are given, the enabled mods are used. Dependencies are
+// resolved to the enabled release of each mod, or to the newest matching local
+// release if the mod is not enabled, falling back to the newest local release.
+// Optional and incompatible dependencies are only included if their target is
+// part of the graph.
+func (m *Manager) Graph(mods []ModIdent) *Graph {
+	nodes := map[string]*GraphNode{}
+	releases := map[string]*Release{}
+	queue := []string{}
+	visit := func(name string, release *Release) *GraphNode {
+		if node := nodes[name]; node != nil {
+			return node
+		}
+		node := &GraphNode{Name: name, Status: GraphNodeMissing}
+		if release != nil {
+			node.Version = &release.Version
+			node.Status = GraphNodeOk
+			releases[name] = release
+			queue = append(queue, name)
+		}
+		nodes[name] = node
+		return node
+	}
+
+	if len(mods) == 0 {
+		for _, mod := range m.mods {
+			if release := mod.GetEnabledRelease(); release != nil {
+				visit(mod.Name, release)
+			}
+		}
+	}
+	for _, ident := range mods {
+		var release *Release
+		if mod := m.mods[ident.Name]; mod != nil {
+			release = mod.GetRelease(ident.Version)
+		}
+		visit(ident.Name, release)
+	}
+
+	// Required dependencies are walked first so that optional edges can be
+	// drawn to every mod that will end up in the graph
+	edges := []GraphEdge{}
+	deferred := []Requirement{}
+	for i := 0; i < len(queue); i++ {
+		name := queue[i]
+		for _, dep := range releases[name].Dependencies {
+			if !dep.isRequired() {
+				deferred = append(deferred, Requirement{Dependent: &ModIdent{Name: name}, Dependency: *dep})
+				continue
+			}
+			edge := GraphEdge{From: name, To: dep.Name, Kind: dep.Kind, Constraint: dep.ToString()}
+			var release *Release
+			if mod := m.mods[dep.Name]; mod != nil {
+				release = mod.GetEnabledRelease()
+				if release == nil {
+					release = mod.GetMatchingRelease(dep)
+				}
+				if release == nil {
+					release = mod.GetLatestRelease()
+				}
+			}
+			node := visit(dep.Name, release)
+			edge.Satisfied = node.Version != nil && dep.Test(node.Version)
+			edges = append(edges, edge)
+		}
+	}
+	for _, req := range deferred {
+		dep := &req.Dependency
+		node := nodes[dep.Name]
+		if node == nil || node.Version == nil {
+			continue
+		}
+		edges = append(edges, GraphEdge{
+			From:       req.Dependent.Name,
+			To:         dep.Name,
+			Kind:       dep.Kind,
+			Constraint: dep.ToString(),
+			Satisfied:  compatible(dep, node.Version),
+		})
+	}
+
+	for _, edge := range edges {
+		if node := nodes[edge.To]; !edge.Satisfied && node.Status == GraphNodeOk {
+			node.Status = GraphNodeUnsatisfied
+		}
+	}
+
+	graph := Graph{Nodes: []GraphNode{}, Edges: edges}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, *node)
+	}
+	slices.SortFunc(graph.Nodes, func(a, b GraphNode) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	slices.SortStableFunc(graph.Edges, func(a, b GraphEdge) int {
+		if a.From != b.From {
+			return cmp.Compare(a.From, b.From)
+		}
+		return cmp.Compare(a.To, b.To)
+	})
+	return &graph
+}
+
+var graphEdgeStyle = map[DependencyKind]string{
+	DependencyRequired:       "style=solid",
+	DependencyOptional:       "style=dashed",
+	DependencyHiddenOptional: "style=dotted",
+	DependencyIncompatible:   "style=bold, arrowhead=tee",
+	DependencyNoLoadOrder:    "style=solid, arrowhead=empty",
+}
+
+// WriteDot writes the graph in the Graphviz DOT format.
+func (g *Graph) WriteDot(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph mods {\n")
+	b.WriteString("\tnode [shape=box];\n")
+	for _, node := range g.Nodes {
+		label := node.Name
+		if node.Version != nil {
+			label += "\n" + node.Version.ToString(false)
+		}
+		attrs := fmt.Sprintf("label=%s", dotQuote(label))
+		switch node.Status {
+		case GraphNodeMissing:
+			attrs += ", style=\"filled,dashed\", fillcolor=\"#ffcccc\""
+		case GraphNodeUnsatisfied:
+			attrs += ", style=filled, fillcolor=\"#ffcc88\""
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", dotQuote(node.Name), attrs)
+	}
+	for _, edge := range g.Edges {
+		attrs := fmt.Sprintf("label=%s, %s", dotQuote(edge.Constraint), graphEdgeStyle[edge.Kind])
+		if !edge.Satisfied || edge.Kind == DependencyIncompatible {
+			attrs += ", color=red, fontcolor=red"
+		}
+		fmt.Fprintf(&b, "\t%s -> %s [%s];\n", dotQuote(edge.From), dotQuote(edge.To), attrs)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	s = strings.ReplaceAll(s, "\n", "\\n")
+	return "\"" + s + "\""
+}
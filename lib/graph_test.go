@@ -0,0 +1,47 @@
+package fmm
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"A_1.0.0", "B >= 2.0", "? C", "missing", "? notpresent"},
+		[]string{"B_1.0.0"},
+		[]string{"C_1.0.0", "! B"},
+	)
+	for _, name := range []string{"A", "C"} {
+		_, err := m.Enable(ModIdent{Name: name})
+		require.NoError(t, err)
+	}
+
+	graph := m.Graph(nil)
+	statuses := map[string]GraphNodeStatus{}
+	for _, node := range graph.Nodes {
+		statuses[node.Name] = node.Status
+	}
+	require.Equal(t, map[string]GraphNodeStatus{
+		"A":       GraphNodeOk,
+		"B":       GraphNodeUnsatisfied,
+		"C":       GraphNodeOk,
+		"missing": GraphNodeMissing,
+	}, statuses)
+
+	require.Len(t, graph.Edges, 4)
+	require.Equal(t, GraphEdge{From: "A", To: "B", Kind: DependencyRequired, Constraint: "B >= 2.0.0"}, graph.Edges[0])
+	require.Equal(t, GraphEdge{From: "A", To: "C", Kind: DependencyOptional, Constraint: "? C", Satisfied: true}, graph.Edges[1])
+	require.Equal(t, "! B", graph.Edges[3].Constraint)
+
+	var dot bytes.Buffer
+	require.NoError(t, graph.WriteDot(&dot))
+	require.Contains(t, dot.String(), "\"A\" -> \"C\" [label=\"? C\", style=dashed];")
+
+	marshaled, err := json.Marshal(graph)
+	require.NoError(t, err)
+	require.Contains(t, string(marshaled), `{"name":"missing","status":"missing"}`)
+	require.Contains(t, string(marshaled), `"kind":"optional"`)
+}
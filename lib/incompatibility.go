@@ -0,0 +1,52 @@
+package fmm
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// An Incompatibility is a pair of enabled mods that Factorio will refuse to
+// load together.
+type Incompatibility struct {
+	// The mod that declared the incompatibility.
+	Mod ModIdent
+	// The incompatible mod.
+	Other ModIdent
+}
+
+func (i *Incompatibility) ToString() string {
+	return fmt.Sprintf("%s is incompatible with %s", i.Mod.ToString(), i.Other.ToString())
+}
+
+// CheckIncompatibilities tests the enabled mods against every incompatible
+// ("!") dependency of every enabled release.
+func (m *Manager) CheckIncompatibilities() []Incompatibility {
+	output := []Incompatibility{}
+	for _, mod := range m.mods {
+		release := mod.GetEnabledRelease()
+		if release == nil {
+			continue
+		}
+		for _, dep := range release.Dependencies {
+			if dep.Kind != DependencyIncompatible {
+				continue
+			}
+			other := m.mods[dep.Name]
+			if other == nil || other.Enabled == nil || !dep.excludes(other.Enabled) {
+				continue
+			}
+			output = append(output, Incompatibility{
+				Mod:   ModIdent{mod.Name, &release.Version},
+				Other: ModIdent{other.Name, other.Enabled},
+			})
+		}
+	}
+	slices.SortFunc(output, func(a, b Incompatibility) int {
+		if a.Mod.Name != b.Mod.Name {
+			return cmp.Compare(a.Mod.Name, b.Mod.Name)
+		}
+		return cmp.Compare(a.Other.Name, b.Other.Name)
+	})
+	return output
+}
@@ -0,0 +1,29 @@
+package fmm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckIncompatibilities(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"A_1.0.0", "! B"},
+		[]string{"B_1.0.0"},
+		[]string{"C_1.0.0", "! D"},
+		[]string{"D_1.0.0"},
+	)
+	for _, name := range []string{"A", "B", "C"} {
+		_, err := m.Enable(ModIdent{Name: name})
+		require.NoError(t, err)
+	}
+
+	incompatibilities := m.CheckIncompatibilities()
+	require.Len(t, incompatibilities, 1)
+	require.Equal(t, "A", incompatibilities[0].Mod.Name)
+	require.Equal(t, "B", incompatibilities[0].Other.Name)
+	require.Equal(t, "A 1.0.0 is incompatible with B 1.0.0", incompatibilities[0].ToString())
+
+	_, err := m.Resolve([]ModIdent{{Name: "A"}, {Name: "B"}}, ResolveOptions{})
+	require.ErrorIs(t, err, ErrNoCompatibleRelease)
+}
@@ -0,0 +1,150 @@
+package fmm
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+const indexFilename = "fmm-index.json"
+
+// The format version of the index file. Indexes with a different version are
+// discarded.
+const indexFormatVersion = 1
+
+// releaseIndex caches the parsed releases in the mods directory so that zip
+// files that have not changed since the last run do not need to be opened
+// again. Entries are keyed by filename.
+type releaseIndex struct {
+	Version int                           `json:"version"`
+	Entries map[string]*releaseIndexEntry `json:"entries"`
+}
+
+// releaseIndexEntry is only valid while the size and modification time of the
+// file match.
+type releaseIndexEntry struct {
+	Size         int64         `json:"size"`
+	ModTime      time.Time     `json:"mod_time"`
+	Name         string        `json:"name"`
+	Version      Version       `json:"release_version"`
+	Dependencies []*Dependency `json:"dependencies"`
+}
+
+func readReleaseIndex(path string) *releaseIndex {
+	index := releaseIndex{Version: indexFormatVersion, Entries: map[string]*releaseIndexEntry{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &index
+	}
+	// A missing or corrupt index only makes the scan slower
+	var existing releaseIndex
+	if err := json.Unmarshal(data, &existing); err != nil || existing.Version != indexFormatVersion || existing.Entries == nil {
+		return &index
+	}
+	return &existing
+}
+
+func (i *releaseIndex) write(path string) error {
+	marshaled, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	// The temporary file is ignored by parseMods if fmm is interrupted
+	tmpPath := path + downloadTempSuffix
+	if err := os.WriteFile(tmpPath, marshaled, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// lookup returns the cached release for the given file, if the file has not
+// changed since it was indexed.
+func (i *releaseIndex) lookup(filename string, info os.FileInfo) *Release {
+	entry := i.Entries[filename]
+	if entry == nil || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return nil
+	}
+	return &Release{
+		Name:         entry.Name,
+		Dependencies: entry.Dependencies,
+		Path:         filename,
+		Version:      entry.Version,
+	}
+}
+
+// parseReleases parses the given files in the mods directory, using the index
+// for files that have not changed. Uncached files are parsed concurrently. The
+// results are in the same order as the input. Returns true if the index was
+// modified.
+func (m *Manager) parseReleases(index *releaseIndex, filenames []string) ([]*Release, []error, bool) {
+	releases := make([]*Release, len(filenames))
+	errs := make([]error, len(filenames))
+	infos := make([]os.FileInfo, len(filenames))
+	toParse := []int{}
+	for i, filename := range filenames {
+		info, err := os.Stat(filepath.Join(m.modsPath, filename))
+		// Only zip files are indexed, because editing an unzipped mod does not
+		// change the modification time of its directory
+		if err == nil && info.Mode().IsRegular() {
+			infos[i] = info
+			if release := index.lookup(filename, info); release != nil {
+				releases[i] = release
+				continue
+			}
+		}
+		toParse = append(toParse, i)
+	}
+
+	workers := min(runtime.NumCPU(), len(toParse))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each job writes to a distinct index, so no locking is needed
+			for i := range jobs {
+				releases[i], errs[i] = releaseFromFile(filepath.Join(m.modsPath, filenames[i]))
+			}
+		}()
+	}
+	for _, i := range toParse {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	changed := false
+	for i, filename := range filenames {
+		info, release := infos[i], releases[i]
+		if info == nil || release == nil {
+			if index.Entries[filename] != nil {
+				delete(index.Entries, filename)
+				changed = true
+			}
+			continue
+		}
+		if index.lookup(filename, info) != nil {
+			continue
+		}
+		index.Entries[filename] = &releaseIndexEntry{
+			Size:         info.Size(),
+			ModTime:      info.ModTime(),
+			Name:         release.Name,
+			Version:      release.Version,
+			Dependencies: release.Dependencies,
+		}
+		changed = true
+	}
+	for filename := range index.Entries {
+		if !slices.Contains(filenames, filename) {
+			delete(index.Entries, filename)
+			changed = true
+		}
+	}
+	return releases, errs, changed
+}
@@ -0,0 +1,59 @@
+package fmm
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// ModInfo combines what is known about a mod locally and on the mod portal.
+type ModInfo struct {
+	Name string
+	// Nil if the mod is not installed.
+	Local *Mod
+	// Nil if the mod is internal, or if it could not be fetched from the mod
+	// portal, in which case PortalErr is set.
+	Portal    *PortalModInfo
+	PortalErr error
+	// The newest portal release that is compatible with the base version and
+	// the mod's pin, if the mod is installed and it is newer than every local
+	// release.
+	Update *PortalModRelease
+}
+
+// GetModInfo returns the local and portal information for the given mod.
+// Returns an error only if the mod is neither installed nor on the portal.
+func (m *Manager) GetModInfo(name string) (*ModInfo, error) {
+	info := ModInfo{Name: name, Local: m.mods[name]}
+	if info.Local != nil && info.Local.isInternal {
+		return &info, nil
+	}
+
+	info.Portal, info.PortalErr = m.Portal.GetModInfo(name)
+	if info.Portal == nil {
+		if info.Local == nil {
+			return nil, errors.Join(ErrModNotFoundLocal, info.PortalErr)
+		}
+		return &info, nil
+	}
+
+	if info.Local == nil {
+		return &info, nil
+	}
+	dep := &Dependency{Name: name, Kind: DependencyRequired, Req: VersionAny}
+	if pin := m.pins[name]; pin != nil {
+		dep = pin
+	}
+	if release, err := m.Portal.GetMatchingRelease(dep); err == nil && release.Version.Cmp(&info.Local.GetLatestRelease().Version) == VersionGt {
+		info.Update = release
+	}
+
+	return &info, nil
+}
+
+// GetReleasePath returns the path of the given release on disk.
+func (m *Manager) GetReleasePath(release *Release) string {
+	if mod := m.mods[release.Name]; mod != nil && mod.isInternal {
+		return filepath.Join(m.internalModsPath, release.Path)
+	}
+	return filepath.Join(m.modsPath, release.Path)
+}
@@ -0,0 +1,123 @@
+package fmm
+
+import (
+	"slices"
+	"strings"
+)
+
+// DependencyCycleError is returned when the enabled mods depend on each other
+// in a cycle, which makes it impossible to determine a load order.
+type DependencyCycleError struct {
+	// The mods that make up the cycle. Each mod depends on the next, and the
+	// last mod depends on the first.
+	Mods []string
+}
+
+func (e *DependencyCycleError) Error() string {
+	return "dependency cycle detected: " + strings.Join(append(slices.Clone(e.Mods), e.Mods[0]), " -> ")
+}
+
+// LoadOrder returns the enabled mods in the order that Factorio will load them.
+// A mod is loaded after all of its enabled required, optional and hidden
+// optional dependencies. Dependencies that do not affect load order ("~") are
+// ignored. Ties are broken by name.
+func (m *Manager) LoadOrder() ([]ModIdent, error) {
+	enabled := map[string]*Release{}
+	for _, mod := range m.mods {
+		if release := mod.GetEnabledRelease(); release != nil {
+			enabled[mod.Name] = release
+		}
+	}
+
+	// The number of unloaded dependencies of each mod, and the mods that
+	// depend on it
+	remaining := map[string]int{}
+	dependents := map[string][]string{}
+	for name, release := range enabled {
+		remaining[name] = 0
+		for _, dep := range loadOrderDependencies(release, enabled) {
+			remaining[name]++
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	ready := []string{}
+	for name, count := range remaining {
+		if count == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	output := []ModIdent{}
+	for len(ready) > 0 {
+		slices.SortFunc(ready, compareModNames)
+		name := ready[0]
+		ready = ready[1:]
+		output = append(output, ModIdent{name, &enabled[name].Version})
+		delete(remaining, name)
+		for _, dependent := range dependents[name] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(remaining) > 0 {
+		return nil, &DependencyCycleError{findCycle(remaining, enabled)}
+	}
+
+	return output, nil
+}
+
+// loadOrderDependencies returns the names of the enabled mods that must be
+// loaded before the given release.
+func loadOrderDependencies(release *Release, enabled map[string]*Release) []string {
+	output := []string{}
+	for _, dep := range release.Dependencies {
+		switch dep.Kind {
+		case DependencyRequired, DependencyOptional, DependencyHiddenOptional:
+		default:
+			continue
+		}
+		if enabled[dep.Name] != nil && dep.Name != release.Name && !slices.Contains(output, dep.Name) {
+			output = append(output, dep.Name)
+		}
+	}
+	return output
+}
+
+// findCycle follows dependencies between the given unloaded mods until one is
+// visited twice. Every unloaded mod has at least one unloaded dependency, so a
+// cycle will always be found.
+func findCycle(unloaded map[string]int, enabled map[string]*Release) []string {
+	names := []string{}
+	for name := range unloaded {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, compareModNames)
+
+	path := []string{names[0]}
+	for {
+		current := path[len(path)-1]
+		var next string
+		for _, dep := range loadOrderDependencies(enabled[current], enabled) {
+			if _, ok := unloaded[dep]; ok {
+				next = dep
+				break
+			}
+		}
+		if i := slices.Index(path, next); i >= 0 {
+			return path[i:]
+		}
+		path = append(path, next)
+	}
+}
+
+// compareModNames orders mod names alphabetically, ignoring case.
+func compareModNames(a, b string) int {
+	if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,49 @@
+package fmm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrder(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"base_1.1.0"},
+		[]string{"alpha_1.0.0", "base", "zeta"},
+		[]string{"zeta_1.0.0", "? beta", "~ Gamma"},
+		[]string{"beta_1.0.0", "(?) notenabled"},
+		[]string{"Gamma_1.0.0", "! alpha"},
+		[]string{"notenabled_1.0.0"},
+	)
+	for _, name := range []string{"base", "alpha", "zeta", "beta", "Gamma"} {
+		_, err := m.Enable(ModIdent{Name: name})
+		require.NoError(t, err)
+	}
+
+	order, err := m.LoadOrder()
+	require.NoError(t, err)
+	names := []string{}
+	for _, mod := range order {
+		names = append(names, mod.Name)
+	}
+	require.Equal(t, []string{"base", "beta", "Gamma", "zeta", "alpha"}, names)
+}
+
+func TestLoadOrderCycle(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"a_1.0.0", "b"},
+		[]string{"b_1.0.0", "? c"},
+		[]string{"c_1.0.0", "a"},
+		[]string{"d_1.0.0", "a"},
+	)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		_, err := m.Enable(ModIdent{Name: name})
+		require.NoError(t, err)
+	}
+
+	_, err := m.LoadOrder()
+	var cycleErr *DependencyCycleError
+	require.ErrorAs(t, err, &cycleErr)
+	require.Equal(t, []string{"a", "b", "c"}, cycleErr.Mods)
+	require.Equal(t, "dependency cycle detected: a -> b -> c -> a", err.Error())
+}
@@ -0,0 +1,161 @@
+package fmm
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const lockfileFilename = "fmm-lock.json"
+
+// A Lockfile records the exact releases of a set of enabled mods so that the
+// set can be reproduced elsewhere.
+type Lockfile struct {
+	Mods []LockfileMod `json:"mods"`
+}
+
+type LockfileMod struct {
+	Name    string   `json:"name"`
+	Version *Version `json:"version"`
+	// The name of the release's file on the mod portal. Empty for internal
+	// mods.
+	FileName string `json:"file_name,omitempty"`
+	// Empty if the release is not a zip file.
+	Sha1 string `json:"sha1,omitempty"`
+	// True if the mod is part of the game, such as base or a DLC.
+	Internal bool `json:"internal,omitempty"`
+}
+
+// ParseLockfile reads the lockfile at the given path.
+func ParseLockfile(path string) (*Lockfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Join(errors.New("error reading lockfile"), err)
+	}
+	var lockfile Lockfile
+	if err = json.Unmarshal(data, &lockfile); err != nil {
+		return nil, errors.Join(errors.New("error parsing lockfile"), err)
+	}
+	return &lockfile, nil
+}
+
+// Write saves the lockfile to the given path.
+func (l *Lockfile) Write(path string) error {
+	marshaled, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, marshaled, 0666); err != nil {
+		return errors.Join(errors.New("failed to write lockfile"), err)
+	}
+	return nil
+}
+
+// GetLockfilePath returns the default location of the lockfile, which is in
+// the mods directory.
+func (m *Manager) GetLockfilePath() string {
+	return filepath.Join(m.modsPath, lockfileFilename)
+}
+
+// Lock creates a Lockfile containing every enabled mod.
+func (m *Manager) Lock() (*Lockfile, error) {
+	lockfile := Lockfile{Mods: []LockfileMod{}}
+	for _, ident := range m.GetMods() {
+		mod := m.mods[ident.Name]
+		if mod.Enabled == nil || *mod.Enabled != *ident.Version {
+			continue
+		}
+		if mod.isInternal {
+			lockfile.Mods = append(lockfile.Mods, LockfileMod{Name: ident.Name, Version: ident.Version, Internal: true})
+			continue
+		}
+		release := mod.GetRelease(ident.Version)
+		entry := LockfileMod{
+			Name:     ident.Name,
+			Version:  ident.Version,
+			FileName: fmt.Sprintf("%s_%s.zip", ident.Name, ident.Version.ToString(false)),
+		}
+		path := filepath.Join(m.modsPath, release.Path)
+		if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+			entry.Sha1, err = fileSha1(path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		lockfile.Mods = append(lockfile.Mods, entry)
+	}
+	slices.SortFunc(lockfile.Mods, func(a, b LockfileMod) int {
+		return compareModNames(a.Name, b.Name)
+	})
+	return &lockfile, nil
+}
+
+// SyncLocked disables all mods, then enables exactly the releases in the given
+// lockfile, downloading any that are missing. Dependencies are not resolved.
+// Zip files are verified against their recorded hash, and a release that does
+// not match is not enabled. Internal mods are enabled regardless of their
+// version, which depends on the installed game.
+func (m *Manager) SyncLocked(lockfile *Lockfile) error {
+	m.DisableAll()
+	var errs []error
+	idents := []ModIdent{}
+	for _, entry := range lockfile.Mods {
+		if entry.Internal {
+			if _, err := m.Enable(ModIdent{Name: entry.Name}); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", entry.Name, err))
+			}
+			continue
+		}
+		if entry.Version == nil {
+			errs = append(errs, fmt.Errorf("%s: lockfile entry has no version", entry.Name))
+			continue
+		}
+		idents = append(idents, ModIdent{entry.Name, entry.Version})
+	}
+	if _, err := m.DownloadMissing(idents); err != nil {
+		errs = append(errs, err)
+	}
+	for _, entry := range lockfile.Mods {
+		var release *Release
+		if mod := m.mods[entry.Name]; mod != nil && entry.Version != nil && !entry.Internal {
+			release = mod.GetRelease(entry.Version)
+		}
+		if release == nil {
+			continue
+		}
+		if entry.Sha1 != "" {
+			hash, err := fileSha1(filepath.Join(m.modsPath, release.Path))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", entry.Name, err))
+				continue
+			}
+			if hash != entry.Sha1 {
+				errs = append(errs, fmt.Errorf("%s: %w for %s: expected %s, got %s", entry.Name, ErrChecksumMismatch, release.Path, entry.Sha1, hash))
+				continue
+			}
+		}
+		if _, err := m.Enable(ModIdent{entry.Name, entry.Version}); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func fileSha1(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha1.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,53 @@
+package fmm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockfile(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"base_1.1.0"},
+		[]string{"A_1.0.0"},
+		[]string{"A_1.1.0"},
+		[]string{"B_1.0.0"},
+		[]string{"quality_1.1.0"},
+	)
+	m.mods["quality"].isInternal = true
+	m.modsPath = t.TempDir()
+	for _, filename := range []string{"A_1.0.0.zip", "A_1.1.0.zip", "B_1.0.0.zip"} {
+		require.NoError(t, os.WriteFile(filepath.Join(m.modsPath, filename), []byte(filename), 0666))
+	}
+	for _, ident := range []ModIdent{{"base", nil}, {"A", &Version{1}}, {"B", nil}, {"quality", nil}} {
+		_, err := m.Enable(ident)
+		require.NoError(t, err)
+	}
+
+	lockfile, err := m.Lock()
+	require.NoError(t, err)
+	require.Len(t, lockfile.Mods, 4)
+	require.Equal(t, "A_1.0.0.zip", lockfile.Mods[0].FileName)
+	require.Equal(t, "d68813119fcf598505e51e61f91d54ea026b1565", lockfile.Mods[0].Sha1)
+	require.Equal(t, LockfileMod{Name: "quality", Version: &Version{1, 1}, Internal: true}, lockfile.Mods[3])
+
+	path := filepath.Join(m.modsPath, lockfileFilename)
+	require.NoError(t, lockfile.Write(path))
+	parsed, err := ParseLockfile(path)
+	require.NoError(t, err)
+	require.Equal(t, lockfile, parsed)
+
+	_, err = m.Enable(ModIdent{"A", &Version{1, 1}})
+	require.NoError(t, err)
+	require.NoError(t, m.Disable("B"))
+	require.NoError(t, m.SyncLocked(parsed))
+	require.Equal(t, Version{1}, *m.mods["A"].Enabled)
+	require.NotNil(t, m.mods["B"].Enabled)
+	require.NotNil(t, m.mods["quality"].Enabled)
+
+	require.NoError(t, os.WriteFile(filepath.Join(m.modsPath, "B_1.0.0.zip"), []byte("corrupted"), 0666))
+	require.ErrorContains(t, m.SyncLocked(parsed), "checksum mismatch")
+	require.Nil(t, m.mods["B"].Enabled)
+}
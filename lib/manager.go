@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
+	"time"
 )
 
 // Manager manages mdos for a given game directory. A game directory is
@@ -21,13 +23,20 @@ type Manager struct {
 	internalModsPath string
 	modListJsonPath  string
 	modSettingsPath  string
+	indexPath        string
 	modsPath         string
+	statePath        string
 
-	mods map[string]*Mod
+	diagnostics []Diagnostic
+	mods        map[string]*Mod
+	pins        map[string]*Dependency
 
 	modSettings *ModSettings
 }
 
+// Files in the mods directory that are not mods.
+var reservedModsFiles = []string{"mod-list.json", "mod-settings.dat", stateJsonFilename, lockfileFilename, indexFilename}
+
 type PlayerData struct {
 	Token    string
 	Username string
@@ -46,21 +55,28 @@ func NewManager(gamePath string, modsPath string) (*Manager, error) {
 		DoSave: true,
 		Portal: ModPortal{
 			downloadPath: modsPath,
+			listings:     map[string]*PortalModListing{},
 			mods:         map[string]*PortalModInfo{},
-			server:       "https://mods.factorio.com",
+			server:       DefaultPortalUrl,
 		},
 
 		gamePath:         gamePath,
+		indexPath:        filepath.Join(modsPath, indexFilename),
 		internalModsPath: filepath.Join(gamePath, "data"),
 		modListJsonPath:  filepath.Join(modsPath, "mod-list.json"),
 		modsPath:         modsPath,
 		modSettingsPath:  filepath.Join(modsPath, "mod-settings.dat"),
+		statePath:        filepath.Join(modsPath, stateJsonFilename),
 		mods:             map[string]*Mod{},
+		pins:             map[string]*Dependency{},
 	}
 
 	if err := m.readPlayerData(); err != nil {
 		return nil, errors.Join(errors.New("unable to get player data"), err)
 	}
+	if err := m.readCredentials(); err != nil {
+		return nil, err
+	}
 
 	if !entryExists(m.modsPath) {
 		if err := os.Mkdir("mods", 0755); err != nil {
@@ -76,26 +92,14 @@ func NewManager(gamePath string, modsPath string) (*Manager, error) {
 		return nil, errors.Join(errors.New("error parsing mods"), err)
 	}
 
-	for _, mod := range m.mods {
-		slices.SortFunc(mod.releases, func(a *Release, b *Release) int {
-			switch a.Version.Cmp(&b.Version) {
-			case VersionLt:
-				return -1
-			case VersionGt:
-				return 1
-			case VersionEq:
-				return 0
-			// Should be unreachable
-			default:
-				return 0
-			}
-		})
-	}
-
 	if err := m.parseModList(); err != nil {
 		return nil, errors.Join(errors.New("error parsing mod-list.json"), err)
 	}
 
+	if err := m.parseState(); err != nil {
+		return nil, err
+	}
+
 	if base, _ := m.GetMod("base"); base != nil {
 		m.Portal.baseVersion = &base.GetLatestRelease().Version
 	}
@@ -123,16 +127,11 @@ func (m *Manager) Add(mod ModIdent) (*Version, error) {
 		return nil, err
 	}
 
-	filepath, err := m.Portal.DownloadRelease(mod.Name, mod.Version)
+	release, err := m.downloadRelease(mod.Name, mod.Version)
 	if err != nil {
 		return nil, err
 	}
-	release, err := releaseFromFile(filepath)
-	if err != nil {
-		return nil, err
-	}
-	m.addRelease(release, false)
-	m.Enable(mod)
+	m.Enable(ModIdent{mod.Name, &release.Version})
 	return &release.Version, nil
 }
 
@@ -146,15 +145,46 @@ func (m *Manager) Disable(modName string) error {
 		return ErrModAlreadyDisabled
 	}
 	mod.Enabled = nil
+	mod.auto = false
 	return nil
 }
 
+// Deletes the given release of the mod from the mods directory, or all of its
+// releases if no version is given. The mod is disabled if its enabled release
+// is removed. Returns the versions that were removed.
+func (m *Manager) Remove(ident ModIdent) ([]Version, error) {
+	mod, err := m.GetMod(ident.Name)
+	if err != nil {
+		return nil, err
+	}
+	if mod.isInternal {
+		return nil, ErrModIsInternal
+	}
+	releases := mod.GetReleases()
+	if ident.Version != nil {
+		release := mod.GetRelease(ident.Version)
+		if release == nil {
+			return nil, ErrModNotFoundLocal
+		}
+		releases = []*Release{release}
+	}
+	removed := []Version{}
+	for _, release := range releases {
+		if err := m.removeRelease(mod, release); err != nil {
+			return removed, err
+		}
+		removed = append(removed, release.Version)
+	}
+	return removed, nil
+}
+
 // Requests all non-internal mods to be disabled.
 func (m *Manager) DisableAll() {
 	for _, mod := range m.mods {
 		// base is the only mod that is always enabled by default
 		if mod.Name != "base" {
 			mod.Enabled = nil
+			mod.auto = false
 		}
 	}
 }
@@ -251,6 +281,9 @@ func (m *Manager) Save() error {
 	if err != nil {
 		return errors.Join(errors.New("failed to write mod-list.json"), err)
 	}
+	if err := m.saveState(); err != nil {
+		return err
+	}
 	if m.modSettings != nil {
 		file, err := os.Create(m.modSettingsPath)
 		if err != nil {
@@ -279,6 +312,48 @@ func (m *Manager) SetApiKey(key string) {
 	m.Portal.apiKey = key
 }
 
+// Returns the base URL of the mod portal.
+func (m *Manager) GetPortalUrl() string {
+	return m.Portal.server
+}
+
+// Sets the base URL of the mod portal, which defaults to DefaultPortalUrl.
+func (m *Manager) SetPortalUrl(url string) {
+	m.Portal.server = strings.TrimSuffix(url, "/")
+}
+
+// Sets the directory that mod portal information is cached in, and how long
+// cached information is used before it is revalidated with the portal. An empty
+// directory disables the cache.
+func (m *Manager) SetPortalCache(dir string, ttl time.Duration) {
+	m.Portal.cacheDir = dir
+	m.Portal.cacheTTL = ttl
+}
+
+// Sets whether the mod portal may be contacted. In offline mode, mod portal
+// information is only retrieved from the cache, and nothing can be
+// downloaded.
+func (m *Manager) SetOffline(offline bool) {
+	m.Portal.offline = offline
+}
+
+// Sets the number of releases that are downloaded at the same time.
+func (m *Manager) SetDownloadParallelism(parallelism int) {
+	m.Portal.parallelism = parallelism
+}
+
+// Sets the function that receives download progress events. The function is
+// never called concurrently.
+func (m *Manager) SetDownloadProgressHandler(handler func(DownloadEvent)) {
+	m.Portal.progress = handler
+}
+
+// Sets the function that receives messages about requests to the mod portal
+// that may take a while.
+func (m *Manager) SetStatusHandler(handler func(string)) {
+	m.Portal.status = handler
+}
+
 // Returns the current player data.
 func (m *Manager) GetPlayerData() PlayerData {
 	return m.Portal.playerData
@@ -295,6 +370,22 @@ func (m *Manager) SetPlayerData(playerData PlayerData) {
 	m.Portal.playerData = playerData
 }
 
+// downloadRelease downloads the given release from the mod portal and adds it
+// to the mods directory. If version is nil, the newest compatible release is
+// downloaded.
+func (m *Manager) downloadRelease(name string, version *Version) (*Release, error) {
+	filepath, err := m.Portal.DownloadRelease(name, version)
+	if err != nil {
+		return nil, err
+	}
+	release, err := releaseFromFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	m.addRelease(release, false)
+	return release, nil
+}
+
 func (m *Manager) addRelease(release *Release, isInternal bool) {
 	mod := m.mods[release.Name]
 	if mod == nil {
@@ -305,7 +396,38 @@ func (m *Manager) addRelease(release *Release, isInternal bool) {
 		}
 		m.mods[release.Name] = mod
 	}
-	mod.releases = append(mod.releases, release)
+	// Keep releases sorted from oldest to newest
+	i, _ := slices.BinarySearchFunc(mod.releases, release, func(a *Release, b *Release) int {
+		switch a.Version.Cmp(&b.Version) {
+		case VersionLt:
+			return -1
+		case VersionGt:
+			return 1
+		default:
+			return 0
+		}
+	})
+	mod.releases = slices.Insert(mod.releases, i, release)
+}
+
+// removeRelease deletes the given release from the mods directory. The mod is
+// disabled if the release was enabled, and forgotten if it has no releases
+// left.
+func (m *Manager) removeRelease(mod *Mod, release *Release) error {
+	if err := os.RemoveAll(filepath.Join(m.modsPath, release.Path)); err != nil {
+		return errors.Join(fmt.Errorf("failed to delete %s", release.Path), err)
+	}
+	mod.releases = slices.DeleteFunc(mod.releases, func(other *Release) bool {
+		return other == release
+	})
+	if mod.Enabled != nil && *mod.Enabled == release.Version {
+		mod.Enabled = nil
+		mod.auto = false
+	}
+	if len(mod.releases) == 0 {
+		delete(m.mods, mod.Name)
+	}
+	return nil
 }
 
 func (m *Manager) parseModList() error {
@@ -367,18 +489,35 @@ func (m *Manager) parseMods() error {
 		return errors.Join(errors.New("could not read mods directory"), err)
 	}
 
+	filenames := []string{}
 	for _, entry := range entries {
 		filename := entry.Name()
-		if filename == "mod-list.json" || filename == "mod-settings.dat" {
+		// Partial downloads are left behind if fmm is interrupted
+		if slices.Contains(reservedModsFiles, filename) || strings.HasSuffix(filename, downloadTempSuffix) {
 			continue
 		}
-		release, err := releaseFromFile(filepath.Join(m.modsPath, filename))
-		if err != nil {
-			return errors.Join(errors.New(fmt.Sprint("invalid mod ", filename)), err)
+		filenames = append(filenames, filename)
+	}
+
+	index := readReleaseIndex(m.indexPath)
+	releases, errs, changed := m.parseReleases(index, filenames)
+	// Releases are added in directory order so that the result does not depend
+	// on which entries were cached
+	for i, release := range releases {
+		if errs[i] != nil {
+			// A single bad entry should not make every mod unusable
+			m.diagnostics = append(m.diagnostics, Diagnostic{filepath.Join(m.modsPath, filenames[i]), errs[i]})
+			continue
 		}
 		m.addRelease(release, false)
 	}
 
+	if changed {
+		// The index is only an optimization, so failing to write it is not an
+		// error
+		index.write(m.indexPath)
+	}
+
 	return nil
 }
 
@@ -412,64 +551,6 @@ func entryExists(pathParts ...string) bool {
 	return err == nil
 }
 
-func (m *Manager) ExpandDependencies(mods []ModIdent, fetchFromPortal bool) []ModIdent {
-	visited := map[string]bool{}
-	toVisit := []Dependency{}
-	for _, mod := range mods {
-		toVisit = append(toVisit, Dependency{
-			Name:    mod.Name,
-			Version: mod.Version,
-			Kind:    DependencyRequired,
-			Req:     VersionEq,
-		})
-	}
-	output := []ModIdent{}
-
-	for i := 0; i < len(toVisit); i += 1 {
-		dep := toVisit[i]
-		if visited[dep.Name] {
-			continue
-		}
-		visited[dep.Name] = true
-		var ident *ModIdent
-		var deps []*Dependency
-		mod, err := m.GetMod(dep.Name)
-		if err != nil && err != ErrModNotFoundLocal {
-			fmt.Println(err)
-		}
-		if mod != nil {
-			release := mod.GetMatchingRelease(&dep)
-			if release != nil {
-				ident = &ModIdent{Name: release.Name, Version: &release.Version}
-				deps = release.Dependencies
-			}
-		}
-		if ident == nil && fetchFromPortal {
-			var release *PortalModRelease
-			release, err = m.Portal.GetMatchingRelease(&dep)
-			if err == nil {
-				ident = &ModIdent{dep.Name, &release.Version}
-				deps = release.InfoJson.Dependencies
-			}
-		}
-		if err != nil {
-			fmt.Println(err)
-			continue
-		}
-		if ident == nil {
-			panic("Unreachable")
-		}
-		output = append(output, *ident)
-		for _, dep := range deps {
-			if dep.Kind == DependencyRequired || dep.Kind == DependencyNoLoadOrder {
-				toVisit = append(toVisit, *dep)
-			}
-		}
-	}
-
-	return output
-}
-
 func (m *Manager) MergeStartupModSettings(input PropertyTree) error {
 	if input == nil {
 		return nil
@@ -510,21 +591,107 @@ func (m *Manager) MergeStartupModSettings(input PropertyTree) error {
 	return nil
 }
 
-func (m *Manager) CheckDownloadUpdates(mods []ModIdent) {
+// A ModUpdate is a newer release of a mod that is available on the mod portal.
+type ModUpdate struct {
+	Name string
+	// The enabled release of the mod, or its newest local release if it is not
+	// enabled. Nil if the mod is not installed.
+	From *Version
+	// The newest allowed release. Nil if the mod is held back by its pin and
+	// no allowed release is newer than From.
+	Release *PortalModRelease
+	// The newest release that is excluded by the pin of the mod, if any.
+	HeldBack *Version
+}
+
+// CheckDownloadUpdates downloads the newest compatible release of each of the
+// given mods, or of all mods if none are given. Pinned mods are only updated
+// to the newest release that satisfies their pin. Returns the releases that
+// were downloaded.
+func (m *Manager) CheckDownloadUpdates(mods []ModIdent) ([]ModIdent, error) {
+	updates, err := m.GetUpdates(mods)
+	downloaded, downloadErr := m.DownloadUpdates(updates)
+	return downloaded, errors.Join(err, downloadErr)
+}
+
+// DownloadUpdates downloads the releases of the given updates. Returns the
+// releases that were downloaded.
+func (m *Manager) DownloadUpdates(updates []ModUpdate) ([]ModIdent, error) {
+	releases := []*PortalModRelease{}
+	for _, update := range updates {
+		if update.Release != nil {
+			releases = append(releases, update.Release)
+		}
+	}
+	return m.downloadReleases(releases)
+}
+
+// GetUpdates returns the newest compatible release of each of the given mods,
+// or of all mods if none are given, if it is newer than the given version or
+// the newest local release. Pinned mods are only updated to the newest release
+// that satisfies their pin, and are also returned if their pin holds them back
+// from a newer release. The returned error does not prevent the other updates
+// from being returned.
+func (m *Manager) GetUpdates(mods []ModIdent) ([]ModUpdate, error) {
 	if len(mods) == 0 {
 		mods = m.GetLatestMods()
 	}
+	names := []string{}
+	for _, mod := range mods {
+		if local, _ := m.GetMod(mod.Name); local == nil || !local.isInternal {
+			names = append(names, mod.Name)
+		}
+	}
+	// If prefetching fails, each mod is fetched individually instead
+	errs := []error{}
+	if err := m.Portal.Prefetch(names); err != nil {
+		errs = append(errs, err)
+	}
+
+	updates := []ModUpdate{}
 	for _, mod := range mods {
-		info, err := m.Portal.GetModInfo(mod.Name)
+		local, _ := m.GetMod(mod.Name)
+		if local != nil && local.isInternal {
+			continue
+		}
+		var from *Version
+		if local != nil {
+			from = local.Enabled
+			if from == nil {
+				from = &local.GetLatestRelease().Version
+			}
+			if mod.Version == nil {
+				mod.Version = &local.GetLatestRelease().Version
+			}
+		}
+		dep := &Dependency{Name: mod.Name, Kind: DependencyRequired, Req: VersionAny}
+		if pin := m.pins[mod.Name]; pin != nil {
+			dep = pin
+		}
+		newest, err := m.Portal.GetNewestVersion(dep)
 		if err != nil {
-			fmt.Println(err)
+			errs = append(errs, fmt.Errorf("%s: %w", mod.Name, err))
 			continue
 		}
-		if len(info.Releases) == 0 {
-			continue // WTF?
+		heldBack := m.GetHeldBack(ModIdent{mod.Name, newest})
+		if newest.Cmp(mod.Version) != VersionGt {
+			if heldBack != nil {
+				updates = append(updates, ModUpdate{Name: mod.Name, From: from, HeldBack: heldBack})
+			}
+			continue
+		}
+		// The prefetched releases do not include their dependencies, so the
+		// full information is needed to check the base version
+		release, err := m.Portal.GetMatchingRelease(dep)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", mod.Name, err))
+			continue
 		}
-		if info.Releases[len(info.Releases)-1].Version.Cmp(mod.Version) == VersionGt {
-			m.Portal.DownloadLatestRelease(mod.Name)
+		if release.Version.Cmp(mod.Version) == VersionGt {
+			updates = append(updates, ModUpdate{mod.Name, from, release, heldBack})
+		} else if heldBack != nil {
+			updates = append(updates, ModUpdate{Name: mod.Name, From: from, HeldBack: heldBack})
 		}
 	}
+	return updates, errors.Join(errs...)
 }
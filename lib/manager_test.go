@@ -1,7 +1,10 @@
 package fmm
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -30,3 +33,124 @@ func TestManager(t *testing.T) {
 		require.Equal(t, release.Version.Cmp(&expected.version), VersionEq)
 	}
 }
+
+func TestRemove(t *testing.T) {
+	server := newTestPortal(t)
+	m := newPortalManager(t, server)
+	for _, version := range []Version{{0, 12}, {0, 13}} {
+		_, err := m.Add(ModIdent{Name: "flib", Version: &version})
+		require.NoError(t, err)
+	}
+	require.NoError(t, m.Save())
+	// Directories and symlinks are removed without touching their target
+	target := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(target, "info.json"), []byte(`{"name": "linked", "version": "1.0.0"}`), 0666))
+	require.NoError(t, os.Symlink(target, filepath.Join(m.modsPath, "linked")))
+	require.NoError(t, os.Mkdir(filepath.Join(m.modsPath, "unzipped_1.0.0"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(m.modsPath, "unzipped_1.0.0", "info.json"), []byte(`{"name": "unzipped", "version": "1.0.0"}`), 0666))
+	m, err := NewManager(m.gamePath, m.modsPath)
+	require.NoError(t, err)
+
+	// The enabled release is 0.13.0, so removing 0.12.0 leaves it enabled
+	removed, err := m.Remove(ModIdent{Name: "flib", Version: &Version{0, 12}})
+	require.NoError(t, err)
+	require.Equal(t, []Version{{0, 12}}, removed)
+	require.NoFileExists(t, filepath.Join(m.modsPath, "flib_0.12.0.zip"))
+	flib, err := m.GetMod("flib")
+	require.NoError(t, err)
+	require.Equal(t, Version{0, 13}, *flib.Enabled)
+
+	removed, err = m.Remove(ModIdent{Name: "flib"})
+	require.NoError(t, err)
+	require.Equal(t, []Version{{0, 13}}, removed)
+	_, err = m.GetMod("flib")
+	require.ErrorIs(t, err, ErrModNotFoundLocal)
+
+	_, err = m.Remove(ModIdent{Name: "linked"})
+	require.NoError(t, err)
+	require.NoFileExists(t, filepath.Join(m.modsPath, "linked"))
+	require.FileExists(t, filepath.Join(target, "info.json"))
+	_, err = m.Remove(ModIdent{Name: "unzipped"})
+	require.NoError(t, err)
+	require.NoDirExists(t, filepath.Join(m.modsPath, "unzipped_1.0.0"))
+
+	_, err = m.Remove(ModIdent{Name: "base"})
+	require.ErrorIs(t, err, ErrModIsInternal)
+	require.DirExists(t, filepath.Join(m.gamePath, "data", "base"))
+	_, err = m.Remove(ModIdent{Name: "flib", Version: &Version{0, 14}})
+	require.ErrorIs(t, err, ErrModNotFoundLocal)
+
+	// The mod list no longer contains the removed mods
+	require.NoError(t, m.Save())
+	m, err = NewManager(m.gamePath, m.modsPath)
+	require.NoError(t, err)
+	require.Len(t, m.GetMods(), 1)
+}
+
+func TestParseModsDiagnostics(t *testing.T) {
+	server := newTestPortal(t)
+	m := newPortalManager(t, server)
+	_, err := m.Add(ModIdent{Name: "flib"})
+	require.NoError(t, err)
+	require.NoError(t, m.Save())
+
+	for name, data := range map[string]string{
+		".DS_Store":            "",
+		"broken_1.0.0.zip":     "not a zip file",
+		"flib_0.12.0.zip.part": "partial",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(m.modsPath, name), []byte(data), 0666))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(m.modsPath, "empty"), 0755))
+
+	m, err = NewManager(m.gamePath, m.modsPath)
+	require.NoError(t, err)
+	flib, err := m.GetMod("flib")
+	require.NoError(t, err)
+	require.NotNil(t, flib.Enabled)
+
+	paths := []string{}
+	for _, diagnostic := range m.GetDiagnostics() {
+		paths = append(paths, filepath.Base(diagnostic.Path))
+		require.Error(t, diagnostic.Err)
+	}
+	require.ElementsMatch(t, []string{".DS_Store", "broken_1.0.0.zip", "empty"}, paths)
+}
+
+func TestParseModsIndex(t *testing.T) {
+	server := newTestPortal(t)
+	m := newPortalManager(t, server)
+	_, err := m.Add(ModIdent{Name: "bigmod"})
+	require.NoError(t, err)
+	_, err = m.Add(ModIdent{Name: "flib", Version: &Version{0, 13}})
+	require.NoError(t, err)
+	require.NoError(t, m.Save())
+	require.NoError(t, os.WriteFile(filepath.Join(m.modsPath, "broken_1.0.0.zip"), []byte("not a zip file"), 0666))
+
+	require.NoError(t, os.RemoveAll(m.indexPath))
+	cold, err := NewManager(m.gamePath, m.modsPath)
+	require.NoError(t, err)
+	require.FileExists(t, m.indexPath)
+	warm, err := NewManager(m.gamePath, m.modsPath)
+	require.NoError(t, err)
+	require.Equal(t, cold.mods, warm.mods)
+	require.Equal(t, cold.diagnostics, warm.diagnostics)
+
+	// Unchanged files are read from the index
+	index := readReleaseIndex(m.indexPath)
+	require.NotContains(t, index.Entries, "broken_1.0.0.zip")
+	entry := index.Entries["flib_0.13.0.zip"]
+	require.NotNil(t, entry)
+	entry.Dependencies = nil
+	require.NoError(t, index.write(m.indexPath))
+	m, err = NewManager(m.gamePath, m.modsPath)
+	require.NoError(t, err)
+	require.Empty(t, m.mods["flib"].GetRelease(&Version{0, 13}).Dependencies)
+
+	// Changed files are parsed again
+	path := filepath.Join(m.modsPath, "flib_0.13.0.zip")
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)))
+	m, err = NewManager(m.gamePath, m.modsPath)
+	require.NoError(t, err)
+	require.Equal(t, cold.mods["flib"], m.mods["flib"])
+}
@@ -5,12 +5,28 @@ type Mod struct {
 	Enabled    *Version
 	releases   []*Release
 	isInternal bool
+	// True if the mod was enabled only to satisfy the dependencies of another
+	// mod.
+	auto bool
+}
+
+// GetReleases returns the local releases of the mod, oldest first.
+func (m *Mod) GetReleases() []*Release {
+	return append([]*Release{}, m.releases...)
 }
 
 func (m *Mod) GetLatestRelease() *Release {
 	return m.releases[len(m.releases)-1]
 }
 
+// GetEnabledRelease returns the release that is currently enabled, if any.
+func (m *Mod) GetEnabledRelease() *Release {
+	if m.Enabled == nil {
+		return nil
+	}
+	return m.GetRelease(m.Enabled)
+}
+
 func (m *Mod) GetRelease(version *Version) *Release {
 	if version == nil {
 		return m.GetLatestRelease()
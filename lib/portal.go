@@ -1,6 +1,7 @@
 package fmm
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -11,53 +12,195 @@ import (
 	"net/url"
 	"os"
 	"path"
-
-	"github.com/cavaliergopher/grab/v3"
+	"strings"
+	"time"
 )
 
+const DefaultPortalUrl = "https://mods.factorio.com"
+
 type ModPortal struct {
 	apiKey       string
 	baseVersion  *Version
+	cacheDir     string
+	cacheTTL     time.Duration
 	downloadPath string
+	listings     map[string]*PortalModListing
 	mods         map[string]*PortalModInfo
+	offline      bool
+	parallelism  int
 	playerData   PlayerData
+	progress     func(DownloadEvent)
 	server       string
+	status       func(string)
 }
 
-// GetModInfo fetches information for the given mod from the mod portal.
+// GetModInfo fetches information for the given mod from the mod portal. If a
+// cache directory is set, the information is cached on disk and only
+// revalidated with the portal once it is older than the cache TTL. In offline
+// mode, only the cache is used.
 func (p *ModPortal) GetModInfo(name string) (*PortalModInfo, error) {
 	if mod := p.mods[name]; mod != nil {
 		return mod, nil
 	}
 
-	fmt.Println("fetching info for", name) // TODO: Relocate this
+	entry := p.readCache(name)
+	if p.offline {
+		if entry == nil {
+			return nil, fmt.Errorf("%s is not in the portal cache: %w", name, ErrPortalOffline)
+		}
+		p.mods[name] = entry.Info
+		return entry.Info, nil
+	}
+	if entry != nil && entry.fresh(p.cacheTTL) {
+		p.mods[name] = entry.Info
+		return entry.Info, nil
+	}
+
+	if p.status != nil {
+		p.status("fetching info for " + name)
+	}
 	url, err := url.JoinPath(p.server, "api/mods", name, "full")
 	if err != nil {
 		return nil, err
 	}
-	res, err := http.Get(url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	if res.StatusCode != http.StatusOK {
-		return nil, errors.New(fmt.Sprintf("%s was not found on the mod portal", name))
+	if entry != nil && entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
 	}
-
-	body, err := io.ReadAll(res.Body)
+	if entry != nil && entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
+		if entry != nil {
+			// Fall back to stale information rather than failing
+			p.mods[name] = entry.Info
+			return entry.Info, nil
+		}
 		return nil, err
 	}
-	res.Body.Close()
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		var mod PortalModInfo
+		if err := json.NewDecoder(res.Body).Decode(&mod); err != nil {
+			return nil, err
+		}
+		entry = &portalCacheEntry{
+			ETag:         res.Header.Get("ETag"),
+			LastModified: res.Header.Get("Last-Modified"),
+			Info:         &mod,
+		}
+	case http.StatusNotModified:
+		if entry == nil {
+			return nil, fmt.Errorf("unexpected response from the mod portal for %s", name)
+		}
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%s was not found on the mod portal", name)
+	default:
+		if entry != nil {
+			// Fall back to stale information during an outage
+			p.mods[name] = entry.Info
+			return entry.Info, nil
+		}
+		return nil, errors.Join(fmt.Errorf("failed to fetch %s from the mod portal", name), readPortalError(res))
+	}
+
+	entry.FetchedAt = time.Now()
+	// The cache is only an optimization, so failing to write it is not an
+	// error
+	p.writeCache(name, entry)
+	p.mods[name] = entry.Info
+
+	return entry.Info, nil
+}
 
-	var mod PortalModInfo
-	err = json.Unmarshal(body, &mod)
+// The number of mods that are requested at once by Prefetch.
+const prefetchBatchSize = 100
+
+// Prefetch fetches the releases of the given mods from the mod portal's list
+// of mods, many at a time, so that GetNewestRelease does not need a request
+// for each mod. Mods that are cached on disk or in memory are skipped.
+func (p *ModPortal) Prefetch(names []string) error {
+	if p.offline {
+		return nil
+	}
+	toFetch := []string{}
+	for _, name := range names {
+		if p.mods[name] != nil || p.listings[name] != nil {
+			continue
+		}
+		if entry := p.readCache(name); entry != nil && entry.fresh(p.cacheTTL) {
+			continue
+		}
+		toFetch = append(toFetch, name)
+	}
+	if len(toFetch) == 0 {
+		return nil
+	}
+
+	listUrl, err := url.JoinPath(p.server, "api/mods")
 	if err != nil {
-		return nil, err
+		return err
 	}
+	for start := 0; start < len(toFetch); start += prefetchBatchSize {
+		batch := toFetch[start:min(start+prefetchBatchSize, len(toFetch))]
+		query := url.Values{"namelist": {strings.Join(batch, ",")}, "page_size": {"max"}}
+		res, err := http.Get(listUrl + "?" + query.Encode())
+		if err != nil {
+			return err
+		}
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return fmt.Errorf("failed to fetch the mod list from the mod portal: %s", res.Status)
+		}
+		var list portalModList
+		err = json.NewDecoder(res.Body).Decode(&list)
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+		for i := range list.Results {
+			p.listings[list.Results[i].Name] = &list.Results[i]
+		}
+	}
+	return nil
+}
 
-	p.mods[name] = &mod
+// GetNewestVersion returns the version of the newest release matching the
+// given dependency. Unlike GetMatchingRelease, it uses the prefetched list of
+// releases if there is one, which does not include dependencies, so the base
+// version is only compared to the release's Factorio version.
+func (p *ModPortal) GetNewestVersion(dep *Dependency) (*Version, error) {
+	if p.mods[dep.Name] == nil && p.listings[dep.Name] == nil {
+		if _, err := p.GetModInfo(dep.Name); err != nil {
+			return nil, err
+		}
+	}
+	releases := p.knownReleases(dep.Name)
+	for i := len(releases) - 1; i >= 0; i-- {
+		release := &releases[i]
+		if dep.Test(&release.Version) && release.compatibleWithBaseVersion(p.baseVersion) {
+			return &release.Version, nil
+		}
+	}
+	return nil, ErrNoCompatibleRelease
+}
 
-	return &mod, nil
+// knownReleases returns the releases of the given mod that have already been
+// fetched, preferring the full information over the prefetched list.
+func (p *ModPortal) knownReleases(name string) []PortalModRelease {
+	if info := p.mods[name]; info != nil {
+		return info.Releases
+	}
+	if listing := p.listings[name]; listing != nil {
+		return listing.Releases
+	}
+	return nil
 }
 
 // GetMatchingRelease fetches information for the newest release matching the given dependency.
@@ -80,32 +223,15 @@ func (p *ModPortal) GetMatchingRelease(dep *Dependency) (*PortalModRelease, erro
 // DownloadMatchingRelease downloads the latest mod release matching the given dependency.
 // Returns the filepath of the newly downloaded mod.
 func (p *ModPortal) DownloadMatchingRelease(dep *Dependency) (string, error) {
-	if p.playerData.Token == "" {
-		return "", errors.New("token was not specified")
-	}
-	if p.playerData.Username == "" {
-		return "", errors.New("username was not specified")
+	if p.offline {
+		return "", ErrPortalOffline
 	}
 	release, err := p.GetMatchingRelease(dep)
 	if err != nil {
 		return "", err
 	}
-
-	downloadUrl := fmt.Sprintf(
-		"%s/%s?username=%s&token=%s",
-		p.server,
-		release.DownloadUrl,
-		p.playerData.Username,
-		p.playerData.Token,
-	)
-	outPath := path.Join(p.downloadPath, release.FileName)
-
-	fmt.Printf("downloading %s\n", release.FileName) // TODO: This doesn't belong here
-	res, err := grab.Get(outPath, downloadUrl)
-	if err != nil {
-		return "", err
-	}
-	return res.Filename, nil
+	paths, errs := p.downloadReleases([]*PortalModRelease{release})
+	return paths[0], errs[0]
 }
 
 // DownloadLatestRelease downloads the latest release compatible with the current base version.
@@ -129,79 +255,202 @@ func (p *ModPortal) DownloadRelease(name string, version *Version) (string, erro
 	})
 }
 
-// UploadMod uploads the given file to the mod portal.
+// UploadMod uploads the given file to the mod portal as a new release of an
+// existing mod. The file is validated before anything is uploaded.
 func (p *ModPortal) UploadMod(filepath string) error {
-	// Init upload
-	initUploadBody := &bytes.Buffer{}
-	w := multipart.NewWriter(initUploadBody)
-	ident := NewModIdent(path.Base(filepath))
-	w.WriteField("mod", ident.Name)
-	w.Close()
-	url, err := url.JoinPath(p.server, "api/v2/mods/releases/init_upload")
+	info, err := validateModZip(filepath)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest(http.MethodPost, url, initUploadBody)
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.apiKey))
-	req.Header.Set("Content-Type", w.FormDataContentType())
-	res, err := http.DefaultClient.Do(req)
+	uploadUrl, err := p.initUpload("api/v2/mods/releases/init_upload", info.Name)
 	if err != nil {
 		return err
 	}
+	return p.finishUpload(uploadUrl, filepath, nil)
+}
+
+type ModInitUploadRes struct {
+	UploadUrl string `json:"upload_url"`
+}
+
+// initUpload requests an upload URL for the given mod from the given endpoint.
+func (p *ModPortal) initUpload(endpoint string, name string) (string, error) {
 	var decoded ModInitUploadRes
-	err = json.NewDecoder(res.Body).Decode(&decoded)
-	if err != nil {
-		return err
+	if err := p.postForm(endpoint, url.Values{"mod": {name}}, &decoded); err != nil {
+		return "", err
 	}
-	if res.StatusCode != http.StatusOK {
-		return errors.New(*decoded.Message)
+	if decoded.UploadUrl == "" {
+		return "", errors.New("the mod portal did not return an upload URL")
 	}
-	defer res.Body.Close()
+	return decoded.UploadUrl, nil
+}
 
-	// Open file
+// finishUpload uploads the given file and form fields to an upload URL that was
+// returned by initUpload.
+func (p *ModPortal) finishUpload(uploadUrl string, filepath string, fields url.Values) error {
 	file, err := os.Open(filepath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	fmt.Printf("uploading %s\n", filepath) // TODO: Relocate this
+	if p.status != nil {
+		p.status("uploading " + filepath)
+	}
 
-	// Upload file
-	uploadBody := &bytes.Buffer{}
-	w = multipart.NewWriter(uploadBody)
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for key, values := range fields {
+		for _, value := range values {
+			w.WriteField(key, value)
+		}
+	}
 	part, err := w.CreateFormFile("file", path.Base(file.Name()))
-	io.Copy(part, file)
-	w.Close()
-
-	r, err := http.NewRequest("POST", *decoded.UploadUrl, uploadBody)
 	if err != nil {
 		return err
 	}
-	r.Header.Add("Content-Type", w.FormDataContentType())
-	http.DefaultClient.Do(r)
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
 
+	req, err := http.NewRequest(http.MethodPost, uploadUrl, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return readPortalError(res)
+	}
 	return nil
 }
 
-type ModInitUploadRes struct {
-	UploadUrl *string `json:"upload_url"`
-	Message   *string // When an error occurs
+// postForm sends the given fields to a mod portal API endpoint as a multipart
+// form, authorized with the API key, and decodes the response into result.
+func (p *ModPortal) postForm(endpoint string, fields url.Values, result any) error {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for key, values := range fields {
+		for _, value := range values {
+			w.WriteField(key, value)
+		}
+	}
+	w.Close()
+	url, err := url.JoinPath(p.server, endpoint)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.apiKey))
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return readPortalError(res)
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// A PortalError is an error that was returned by the mod portal or
+// authentication API.
+type PortalError struct {
+	StatusCode int
+	// The error code, e.g. 'InvalidApiKey'. Empty if the response did not
+	// include one.
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *PortalError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("%s (%s)", e.Message, e.Code)
+}
+
+func readPortalError(res *http.Response) error {
+	portalErr := PortalError{StatusCode: res.StatusCode}
+	// The body is not always JSON, in which case only the status is reported
+	json.NewDecoder(res.Body).Decode(&portalErr)
+	return &portalErr
+}
+
+// validateModZip checks that the given zip file is a valid mod whose name and
+// version match its filename, and returns its info.json.
+func validateModZip(filepath string) (*infoJson, error) {
+	filename := path.Base(filepath)
+	ident := NewModIdent(filename)
+	if !strings.HasSuffix(filename, ".zip") || ident.Version == nil {
+		return nil, fmt.Errorf("%s: filename must be in the format 'name_version.zip'", filename)
+	}
+	info, err := readZipInfoJson(filepath)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("%s: invalid mod", filename), err)
+	}
+	if info.Name != ident.Name {
+		return nil, fmt.Errorf("%s: info.json name '%s' does not match the filename", filename, info.Name)
+	}
+	if info.Version.Cmp(ident.Version) != VersionEq {
+		return nil, fmt.Errorf("%s: info.json version %s does not match the filename", filename, info.Version.ToString(false))
+	}
+
+	r, err := zip.OpenReader(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	// The folder may also be named without the version, but there must only
+	// be one
+	folder := ""
+	for _, file := range r.File {
+		top, _, _ := strings.Cut(file.Name, "/")
+		if folder == "" && (top == ident.Name || top == strings.TrimSuffix(filename, ".zip")) {
+			folder = top
+		}
+		if top != folder {
+			return nil, fmt.Errorf("%s: all files must be in a '%s' folder, found '%s'", filename, strings.TrimSuffix(filename, ".zip"), file.Name)
+		}
+	}
+	return &info, nil
 }
 
 type PortalModInfo struct {
-	Name     string
-	Releases []PortalModRelease
-	Title    string
+	// The changelog of the newest release.
+	Changelog string             `json:"changelog"`
+	Homepage  string             `json:"homepage"`
+	License   *PortalLicense     `json:"license"`
+	Name      string             `json:"name"`
+	Owner     string             `json:"owner"`
+	Releases  []PortalModRelease `json:"releases"`
+	SourceUrl string             `json:"source_url"`
+	Summary   string             `json:"summary"`
+	Title     string             `json:"title"`
+}
+
+type PortalLicense struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	Url   string `json:"url"`
 }
 
 type PortalModRelease struct {
 	DownloadUrl string   `json:"download_url"`
 	FileName    string   `json:"file_name"`
 	InfoJson    infoJson `json:"info_json"`
+	Sha1        string   `json:"sha1"`
 	Version     Version  `json:"version"`
 }
 
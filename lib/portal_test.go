@@ -0,0 +1,530 @@
+package fmm
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/raiguard/fmm/lib/portaltest"
+	"github.com/stretchr/testify/require"
+)
+
+// newPortalManager creates a Manager for an empty game directory that uses the
+// given fake mod portal.
+func newPortalManager(t *testing.T, server *portaltest.Server) *Manager {
+	gamePath := t.TempDir()
+	modsPath := filepath.Join(gamePath, "mods")
+	require.NoError(t, os.MkdirAll(filepath.Join(gamePath, "data", "base"), 0755))
+	require.NoError(t, os.Mkdir(modsPath, 0755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(gamePath, "data", "base", "info.json"),
+		[]byte(`{"name": "base", "version": "1.1.87", "dependencies": []}`),
+		0666,
+	))
+
+	m, err := NewManager(gamePath, modsPath)
+	require.NoError(t, err)
+	m.SetPortalUrl(server.URL)
+	m.SetPlayerData(PlayerData{Username: server.Username, Token: server.Token})
+	m.SetApiKey(server.ApiKey)
+	return m
+}
+
+func newTestPortal(t *testing.T) *portaltest.Server {
+	server := portaltest.NewServer(
+		portaltest.Mod{Name: "flib", Releases: []portaltest.Release{
+			{Version: "0.12.0", FactorioVersion: "1.1", Dependencies: []string{"base >= 1.1"}},
+			{Version: "0.13.0", FactorioVersion: "1.1", Dependencies: []string{"base >= 1.1"}},
+			{Version: "0.14.0", FactorioVersion: "1.1", Dependencies: []string{"base >= 1.1"}},
+			{Version: "2.0.0", FactorioVersion: "2.0", Dependencies: []string{"base >= 2.0"}},
+		}},
+		portaltest.Mod{Name: "bigmod", Releases: []portaltest.Release{
+			{Version: "1.0.0", FactorioVersion: "1.1", Dependencies: []string{"base", "flib < 0.14"}},
+		}},
+	)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPortalAdd(t *testing.T) {
+	server := newTestPortal(t)
+	m := newPortalManager(t, server)
+
+	resolved, err := m.Resolve([]ModIdent{{Name: "bigmod"}}, ResolveOptions{FetchFromPortal: true})
+	require.NoError(t, err)
+	for _, mod := range resolved {
+		_, err := m.Add(mod)
+		require.NoError(t, err)
+	}
+
+	flib, err := m.GetMod("flib")
+	require.NoError(t, err)
+	require.Equal(t, Version{0, 13}, *flib.Enabled)
+	require.FileExists(t, filepath.Join(m.modsPath, "flib_0.13.0.zip"))
+	require.FileExists(t, filepath.Join(m.modsPath, "bigmod_1.0.0.zip"))
+
+	m.SetPlayerData(PlayerData{Username: "username", Token: "invalid"})
+	_, err = m.Add(ModIdent{Name: "flib", Version: &Version{0, 12}})
+	require.Error(t, err)
+}
+
+func TestPortalCheckDownloadUpdates(t *testing.T) {
+	server := newTestPortal(t)
+	m := newPortalManager(t, server)
+
+	_, err := m.Add(ModIdent{Name: "flib", Version: &Version{0, 12}})
+	require.NoError(t, err)
+	_, err = m.CheckDownloadUpdates(nil)
+	require.NoError(t, err)
+	// 2.0.0 is not compatible with the base version
+	require.FileExists(t, filepath.Join(m.modsPath, "flib_0.14.0.zip"))
+	require.NoFileExists(t, filepath.Join(m.modsPath, "flib_2.0.0.zip"))
+}
+
+func TestPortalUploadMod(t *testing.T) {
+	server := newTestPortal(t)
+	m := newPortalManager(t, server)
+
+	path := filepath.Join(t.TempDir(), "flib_0.15.0.zip")
+	data := portaltest.ReleaseZip("flib", portaltest.Release{Version: "0.15.0", FactorioVersion: "1.1"})
+	require.NoError(t, os.WriteFile(path, data, 0666))
+	require.NoError(t, m.Portal.UploadMod(path))
+
+	uploads := server.Uploads()
+	require.Len(t, uploads, 1)
+	require.Equal(t, "flib", uploads[0].Mod)
+	require.Equal(t, "flib_0.15.0.zip", uploads[0].FileName)
+	require.Equal(t, data, uploads[0].Data)
+
+	// Errors from the portal are returned with their message
+	path = filepath.Join(t.TempDir(), "flib_0.14.0.zip")
+	require.NoError(t, os.WriteFile(path, portaltest.ReleaseZip("flib", portaltest.Release{Version: "0.14.0", FactorioVersion: "1.1"}), 0666))
+	var portalErr *PortalError
+	require.ErrorAs(t, m.Portal.UploadMod(path), &portalErr)
+	require.Equal(t, "InvalidModRelease", portalErr.Code)
+
+	m.SetApiKey("invalid")
+	require.ErrorAs(t, m.Portal.UploadMod(path), &portalErr)
+	require.Equal(t, "InvalidApiKey", portalErr.Code)
+	require.Len(t, server.Uploads(), 1)
+}
+
+func TestValidateModZip(t *testing.T) {
+	dir := t.TempDir()
+	write := func(filename string, files map[string]string) string {
+		var buf bytes.Buffer
+		w := zip.NewWriter(&buf)
+		for name, content := range files {
+			file, err := w.Create(name)
+			require.NoError(t, err)
+			file.Write([]byte(content))
+		}
+		require.NoError(t, w.Close())
+		path := filepath.Join(dir, filename)
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0666))
+		return path
+	}
+	infoJson := `{"name": "flib", "version": "0.15.0"}`
+
+	_, err := validateModZip(write("flib_0.15.0.zip", map[string]string{"flib_0.15.0/info.json": infoJson}))
+	require.NoError(t, err)
+	_, err = validateModZip(write("flib_0.15.0.zip", map[string]string{"flib/info.json": infoJson, "flib/data.lua": ""}))
+	require.NoError(t, err)
+
+	_, err = validateModZip(write("flib.zip", map[string]string{"flib/info.json": infoJson}))
+	require.ErrorContains(t, err, "filename")
+	_, err = validateModZip(write("flib_0.16.0.zip", map[string]string{"flib_0.16.0/info.json": infoJson}))
+	require.ErrorContains(t, err, "version")
+	_, err = validateModZip(write("other_0.15.0.zip", map[string]string{"other_0.15.0/info.json": infoJson}))
+	require.ErrorContains(t, err, "name")
+	_, err = validateModZip(write("flib_0.15.0.zip", map[string]string{"flib_0.15.0/info.json": infoJson, "stray.lua": ""}))
+	require.ErrorContains(t, err, "folder")
+	_, err = validateModZip(write("flib_0.15.0.zip", map[string]string{"info.json": infoJson}))
+	require.Error(t, err)
+}
+
+func TestPortalCache(t *testing.T) {
+	server := newTestPortal(t)
+	cacheDir := t.TempDir()
+	countRequests := func() int {
+		count := 0
+		for _, req := range server.Requests() {
+			if req == "GET /api/mods/flib/full" {
+				count++
+			}
+		}
+		return count
+	}
+
+	m := newPortalManager(t, server)
+	m.SetPortalCache(cacheDir, time.Hour)
+	_, err := m.Portal.GetModInfo("flib")
+	require.NoError(t, err)
+	require.Equal(t, 1, countRequests())
+
+	// Fresh entries are used without contacting the portal
+	m = newPortalManager(t, server)
+	m.SetPortalCache(cacheDir, time.Hour)
+	info, err := m.Portal.GetModInfo("flib")
+	require.NoError(t, err)
+	require.Len(t, info.Releases, 4)
+	require.Equal(t, 1, countRequests())
+
+	// Stale entries are revalidated
+	m = newPortalManager(t, server)
+	m.SetPortalCache(cacheDir, 0)
+	info, err = m.Portal.GetModInfo("flib")
+	require.NoError(t, err)
+	require.Len(t, info.Releases, 4)
+	require.Equal(t, 2, countRequests())
+	require.Equal(t, 1, server.NotModified())
+
+	// Offline mode only uses the cache
+	m = newPortalManager(t, server)
+	m.SetPortalCache(cacheDir, 0)
+	m.SetOffline(true)
+	_, err = m.Portal.GetModInfo("flib")
+	require.NoError(t, err)
+	_, err = m.Portal.GetModInfo("bigmod")
+	require.ErrorIs(t, err, ErrPortalOffline)
+	_, err = m.Portal.DownloadLatestRelease("flib")
+	require.ErrorIs(t, err, ErrPortalOffline)
+	require.Equal(t, 2, countRequests())
+
+	// Stale entries are used if the portal is down
+	server.SetUnavailable(true)
+	m = newPortalManager(t, server)
+	m.SetPortalCache(cacheDir, 0)
+	info, err = m.Portal.GetModInfo("flib")
+	require.NoError(t, err)
+	require.Len(t, info.Releases, 4)
+	_, err = m.Portal.GetModInfo("bigmod")
+	var portalErr *PortalError
+	require.ErrorAs(t, err, &portalErr)
+	require.Equal(t, http.StatusServiceUnavailable, portalErr.StatusCode)
+}
+
+func TestPortalDownloadMissing(t *testing.T) {
+	server := newTestPortal(t)
+	m := newPortalManager(t, server)
+	m.SetDownloadParallelism(2)
+	events := []DownloadEvent{}
+	m.SetDownloadProgressHandler(func(event DownloadEvent) {
+		events = append(events, event)
+	})
+
+	downloaded, err := m.DownloadMissing([]ModIdent{
+		{Name: "flib", Version: &Version{0, 12}},
+		{Name: "flib", Version: &Version{0, 13}},
+		{Name: "bigmod"},
+	})
+	require.NoError(t, err)
+	require.Len(t, downloaded, 3)
+	for _, file := range []string{"flib_0.12.0.zip", "flib_0.13.0.zip", "bigmod_1.0.0.zip"} {
+		require.FileExists(t, filepath.Join(m.modsPath, file))
+	}
+
+	finished := 0
+	for _, event := range events {
+		require.Equal(t, 3, event.FilesTotal)
+		if event.Kind == DownloadFinished {
+			finished++
+			require.Equal(t, finished, event.FilesComplete)
+		}
+	}
+	require.Equal(t, 3, finished)
+
+	// Releases that exist locally are not downloaded again
+	downloaded, err = m.DownloadMissing([]ModIdent{{Name: "flib"}, {Name: "bigmod", Version: &Version{1}}})
+	require.NoError(t, err)
+	require.Empty(t, downloaded)
+
+	m.SetPlayerData(PlayerData{Username: "username", Token: "invalid"})
+	_, err = m.DownloadMissing([]ModIdent{{Name: "flib", Version: &Version{0, 14}}})
+	require.Error(t, err)
+	require.Equal(t, DownloadFailed, events[len(events)-1].Kind)
+}
+
+func TestPortalDownloadChecksum(t *testing.T) {
+	server := newTestPortal(t)
+	m := newPortalManager(t, server)
+
+	server.Corrupt("flib", "0.13.0", []byte("not a zip file"))
+	_, err := m.DownloadMissing([]ModIdent{{Name: "flib", Version: &Version{0, 13}}})
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+	entries, err := os.ReadDir(m.modsPath)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// Leftover partial downloads do not break parsing the mods directory
+	require.NoError(t, os.WriteFile(filepath.Join(m.modsPath, "flib_0.12.0.zip.part"), []byte("partial"), 0666))
+	_, err = NewManager(m.gamePath, m.modsPath)
+	require.NoError(t, err)
+
+	_, err = m.DownloadMissing([]ModIdent{{Name: "flib", Version: &Version{0, 12}}})
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(m.modsPath, "flib_0.12.0.zip"))
+	require.NoFileExists(t, filepath.Join(m.modsPath, "flib_0.12.0.zip.part"))
+}
+
+func TestPortalSearch(t *testing.T) {
+	server := newTestPortal(t)
+	server.AddMod(portaltest.Mod{
+		Name: "LogisticTrainNetwork", Title: "LTN - Logistic Train Network", Owner: "Optera",
+		Category: "content", Downloads: 500,
+		Releases: []portaltest.Release{{Version: "1.18.0", FactorioVersion: "1.1"}},
+	})
+	server.AddMod(portaltest.Mod{
+		Name: "TrainOverhaul", Title: "Train Overhaul", Owner: "someone",
+		Category: "content", Downloads: 100,
+		Releases: []portaltest.Release{{Version: "1.0.0", FactorioVersion: "1.1"}},
+	})
+	server.AddMod(portaltest.Mod{
+		Name: "space-trains", Title: "Space Trains", Owner: "someone",
+		Category: "content", Downloads: 1000,
+		Releases: []portaltest.Release{{Version: "1.0.0", FactorioVersion: "2.0"}},
+	})
+	m := newPortalManager(t, server)
+	m.SetPortalCache(t.TempDir(), time.Hour)
+	names := func(result *SearchResult) []string {
+		output := []string{}
+		for _, mod := range result.Mods {
+			output = append(output, mod.Name)
+		}
+		return output
+	}
+
+	// Defaults to the base version, sorted by downloads
+	result, err := m.Portal.Search(SearchOptions{Query: "train"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"LogisticTrainNetwork", "TrainOverhaul"}, names(result))
+	require.Equal(t, Version{1, 18}, result.Mods[0].LatestRelease.Version)
+
+	result, err = m.Portal.Search(SearchOptions{Query: "train", FactorioVersion: &Version{2, 0}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"space-trains"}, names(result))
+
+	result, err = m.Portal.Search(SearchOptions{Owner: "someone", Category: "content"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"TrainOverhaul"}, names(result))
+
+	result, err = m.Portal.Search(SearchOptions{PageSize: 2, Page: 2})
+	require.NoError(t, err)
+	require.Equal(t, 4, result.Count)
+	require.Equal(t, 2, result.PageCount)
+	require.Equal(t, []string{"bigmod", "flib"}, names(result))
+
+	result, err = m.Portal.Search(SearchOptions{PageSize: 2, Page: 3})
+	require.NoError(t, err)
+	require.Empty(t, result.Mods)
+
+	// The list is fetched once for each Factorio version and then cached
+	require.Equal(t, []string{"GET /api/mods", "GET /api/mods"}, server.Requests())
+	m.SetOffline(true)
+	result, err = m.Portal.Search(SearchOptions{Query: "train"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"LogisticTrainNetwork", "TrainOverhaul"}, names(result))
+	_, err = m.Portal.Search(SearchOptions{FactorioVersion: &Version{0, 18}})
+	require.ErrorIs(t, err, ErrPortalOffline)
+}
+
+func TestPortalModInfo(t *testing.T) {
+	server := newTestPortal(t)
+	server.AddMod(portaltest.Mod{
+		Name: "flib", Title: "Factorio Library", Owner: "raiguard", Summary: "A library",
+		SourceUrl: "https://github.com/factoriolib/flib", License: "MIT",
+		Releases: []portaltest.Release{
+			{Version: "0.12.0", FactorioVersion: "1.1", Dependencies: []string{"base >= 1.1"}},
+			{Version: "0.13.0", FactorioVersion: "1.1", Dependencies: []string{"base >= 1.1"}},
+			{Version: "2.0.0", FactorioVersion: "2.0", Dependencies: []string{"base >= 2.0"}},
+		},
+	})
+	m := newPortalManager(t, server)
+	_, err := m.Add(ModIdent{Name: "flib", Version: &Version{0, 12}})
+	require.NoError(t, err)
+
+	info, err := m.GetModInfo("flib")
+	require.NoError(t, err)
+	require.NoError(t, info.PortalErr)
+	require.Equal(t, "raiguard", info.Portal.Owner)
+	require.Equal(t, "https://github.com/factoriolib/flib", info.Portal.SourceUrl)
+	require.Equal(t, "MIT", info.Portal.License.Name)
+	require.Len(t, info.Portal.Releases, 3)
+	require.Equal(t, Version{0, 12}, *info.Local.Enabled)
+	require.Equal(t, filepath.Join(m.modsPath, "flib_0.12.0.zip"), m.GetReleasePath(info.Local.GetEnabledRelease()))
+	// 2.0.0 is not compatible with the base version
+	require.Equal(t, Version{0, 13}, info.Update.Version)
+
+	// Mods that are not installed have no update
+	info, err = m.GetModInfo("bigmod")
+	require.NoError(t, err)
+	require.Nil(t, info.Local)
+	require.Nil(t, info.Update)
+
+	info, err = m.GetModInfo("base")
+	require.NoError(t, err)
+	require.Nil(t, info.Portal)
+	require.Equal(t, filepath.Join(m.gamePath, "data", "base"), m.GetReleasePath(info.Local.GetLatestRelease()))
+
+	_, err = m.GetModInfo("missing")
+	require.ErrorIs(t, err, ErrModNotFoundLocal)
+}
+
+func TestPortalUpdateChangelog(t *testing.T) {
+	server := newTestPortal(t)
+	server.AddMod(portaltest.Mod{Name: "flib", Releases: []portaltest.Release{
+		{Version: "0.12.0", FactorioVersion: "1.1"},
+		{Version: "0.13.0", FactorioVersion: "1.1", Changelog: testChangelog},
+		{Version: "0.14.0", FactorioVersion: "1.1", Changelog: testChangelog},
+	}})
+	m := newPortalManager(t, server)
+	_, err := m.Add(ModIdent{Name: "flib", Version: &Version{0, 12}})
+	require.NoError(t, err)
+	m.Pin(Dependency{Name: "flib", Version: &Version{0, 13}, Req: VersionEq})
+
+	updates, err := m.GetUpdates(nil)
+	require.NoError(t, err)
+	require.Len(t, updates, 1)
+	require.Equal(t, Version{0, 12}, *updates[0].From)
+	require.Equal(t, Version{0, 13}, updates[0].Release.Version)
+	require.Equal(t, Version{0, 14}, *updates[0].HeldBack)
+
+	// The portal only has the changelog of the newest release
+	changelog, err := m.Portal.GetChangelog("flib")
+	require.NoError(t, err)
+	require.Len(t, changelog.Between(updates[0].From, &updates[0].Release.Version), 1)
+
+	downloaded, err := m.DownloadUpdates(updates)
+	require.NoError(t, err)
+	require.Len(t, downloaded, 1)
+	flib, err := m.GetMod("flib")
+	require.NoError(t, err)
+	changelog, err = m.GetChangelog(flib.GetRelease(&Version{0, 13}))
+	require.NoError(t, err)
+	require.Len(t, changelog, 3)
+	changelog, err = m.GetChangelog(flib.GetRelease(&Version{0, 12}))
+	require.NoError(t, err)
+	require.Empty(t, changelog)
+
+	// Held back mods are reported even if there is nothing to update
+	_, err = m.Enable(ModIdent{Name: "flib", Version: &Version{0, 13}})
+	require.NoError(t, err)
+	updates, err = m.GetUpdates(nil)
+	require.NoError(t, err)
+	require.Len(t, updates, 1)
+	require.Nil(t, updates[0].Release)
+	require.Equal(t, Version{0, 14}, *updates[0].HeldBack)
+	downloaded, err = m.DownloadUpdates(updates)
+	require.NoError(t, err)
+	require.Empty(t, downloaded)
+}
+
+func TestPortalPrefetch(t *testing.T) {
+	server := newTestPortal(t)
+	m := newPortalManager(t, server)
+	_, err := m.Add(ModIdent{Name: "flib", Version: &Version{0, 13}})
+	require.NoError(t, err)
+	_, err = m.Add(ModIdent{Name: "bigmod", Version: &Version{1}})
+	require.NoError(t, err)
+	m.Portal.mods = map[string]*PortalModInfo{}
+	before := len(server.Requests())
+
+	// Only the mod with an update needs its full information
+	updates, err := m.GetUpdates(nil)
+	require.NoError(t, err)
+	require.Len(t, updates, 1)
+	require.Equal(t, Version{0, 14}, updates[0].Release.Version)
+	require.Equal(t, []string{"GET /api/mods", "GET /api/mods/flib/full"}, server.Requests()[before:])
+
+	// Prefetched mods are not fetched again
+	require.NoError(t, m.Portal.Prefetch([]string{"flib", "bigmod"}))
+	require.Len(t, server.Requests(), before+2)
+	ver, err := m.Portal.GetNewestVersion(&Dependency{Name: "bigmod", Kind: DependencyRequired, Req: VersionAny})
+	require.NoError(t, err)
+	require.Equal(t, Version{1}, *ver)
+	require.Len(t, server.Requests(), before+2)
+}
+
+func TestPortalPublishMod(t *testing.T) {
+	server := newTestPortal(t)
+	m := newPortalManager(t, server)
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "description.md"), []byte("# New mod"), 0666))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "details.json"), []byte(`{
+		"summary": "A new mod",
+		"description_file": "description.md",
+		"category": "utilities",
+		"tags": ["logistics", "trains"],
+		"license": "default_mit",
+		"source_url": "https://github.com/example/newmod"
+	}`), 0666))
+	details, err := ParseModDetails(filepath.Join(dir, "details.json"))
+	require.NoError(t, err)
+	require.Equal(t, "# New mod", details.Description)
+
+	path := filepath.Join(dir, "newmod_1.0.0.zip")
+	require.NoError(t, os.WriteFile(path, portaltest.ReleaseZip("newmod", portaltest.Release{Version: "1.0.0", FactorioVersion: "1.1"}), 0666))
+	require.NoError(t, m.Portal.PublishMod(path, details))
+	mod, ok := server.Mod("newmod")
+	require.True(t, ok)
+	require.Equal(t, "A new mod", mod.Summary)
+	require.Equal(t, "# New mod", mod.Description)
+	require.Equal(t, "utilities", mod.Category)
+	require.Equal(t, []string{"logistics", "trains"}, mod.Tags)
+	require.Equal(t, "default_mit", mod.License)
+	require.Equal(t, "https://github.com/example/newmod", mod.SourceUrl)
+	require.True(t, server.Uploads()[0].Publish)
+
+	// The published release can be downloaded
+	_, err = m.Add(ModIdent{Name: "newmod"})
+	require.NoError(t, err)
+
+	// Mods can only be published once
+	var portalErr *PortalError
+	require.ErrorAs(t, m.Portal.PublishMod(path, nil), &portalErr)
+	require.Equal(t, "ModAlreadyExists", portalErr.Code)
+
+	// Empty details are left unchanged
+	require.NoError(t, m.Portal.EditModDetails("newmod", &ModDetails{Title: "New Mod"}))
+	mod, _ = server.Mod("newmod")
+	require.Equal(t, "New Mod", mod.Title)
+	require.Equal(t, "A new mod", mod.Summary)
+	require.ErrorAs(t, m.Portal.EditModDetails("missing", &ModDetails{Title: "Missing"}), &portalErr)
+	require.Equal(t, "UnknownMod", portalErr.Code)
+}
+
+func TestLogin(t *testing.T) {
+	server := newTestPortal(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	_, err := Login(server.URL, server.Username, "invalid", "")
+	var portalErr *PortalError
+	require.ErrorAs(t, err, &portalErr)
+	require.Equal(t, "login-failed", portalErr.Code)
+
+	server.AuthCode = "123456"
+	_, err = Login(server.URL, server.Username, server.Password, "")
+	require.ErrorIs(t, err, ErrAuthCodeRequired)
+	playerData, err := Login(server.URL, server.Username, server.Password, server.AuthCode)
+	require.NoError(t, err)
+	require.Equal(t, PlayerData{Username: server.Username, Token: server.Token}, playerData)
+
+	require.NoError(t, SaveCredentials(playerData))
+	path, err := GetCredentialsPath()
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The credentials are used if the game directory has no player data
+	m := newPortalManager(t, server)
+	m, err = NewManager(m.gamePath, m.modsPath)
+	require.NoError(t, err)
+	require.Equal(t, playerData, m.GetPlayerData())
+}
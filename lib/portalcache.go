@@ -0,0 +1,117 @@
+package fmm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// portalCacheEntry is the on-disk representation of a mod's portal metadata.
+type portalCacheEntry struct {
+	FetchedAt    time.Time      `json:"fetched_at"`
+	ETag         string         `json:"etag,omitempty"`
+	LastModified string         `json:"last_modified,omitempty"`
+	Info         *PortalModInfo `json:"info"`
+}
+
+// portalListCacheEntry is the on-disk representation of the portal's list of
+// mods for a Factorio version.
+type portalListCacheEntry struct {
+	FetchedAt time.Time          `json:"fetched_at"`
+	Results   []PortalModListing `json:"results"`
+}
+
+// cacheHostDir returns the directory that cache entries for the current portal
+// are stored in. Entries are separated by portal so that mirrors do not share
+// a cache.
+func (p *ModPortal) cacheHostDir() string {
+	host := p.server
+	if u, err := url.Parse(p.server); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	host = strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(host)
+	return filepath.Join(p.cacheDir, host)
+}
+
+// cachePath returns the location of the cache entry for the given mod.
+func (p *ModPortal) cachePath(name string) string {
+	return filepath.Join(p.cacheHostDir(), url.PathEscape(name)+".json")
+}
+
+// listCachePath returns the location of the cached list of mods for the given
+// Factorio version, which may be empty for all versions.
+func (p *ModPortal) listCachePath(version string) string {
+	if version == "" {
+		version = "all"
+	}
+	return filepath.Join(p.cacheHostDir(), "lists", version+".json")
+}
+
+func (p *ModPortal) readCache(name string) *portalCacheEntry {
+	var entry portalCacheEntry
+	if !p.readCacheFile(p.cachePath(name), &entry) || entry.Info == nil {
+		return nil
+	}
+	return &entry
+}
+
+func (p *ModPortal) writeCache(name string, entry *portalCacheEntry) error {
+	return p.writeCacheFile(p.cachePath(name), entry)
+}
+
+func (p *ModPortal) readListCache(version string) *portalListCacheEntry {
+	var entry portalListCacheEntry
+	if !p.readCacheFile(p.listCachePath(version), &entry) || entry.Results == nil {
+		return nil
+	}
+	return &entry
+}
+
+func (p *ModPortal) writeListCache(version string, entry *portalListCacheEntry) error {
+	return p.writeCacheFile(p.listCachePath(version), entry)
+}
+
+func (p *ModPortal) readCacheFile(path string, entry any) bool {
+	if p.cacheDir == "" {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, entry) == nil
+}
+
+func (p *ModPortal) writeCacheFile(path string, entry any) error {
+	if p.cacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Join(errors.New("failed to create portal cache directory"), err)
+	}
+	marshaled, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that concurrent processes never read
+	// a partial entry
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, marshaled, 0666); err != nil {
+		return errors.Join(errors.New("failed to write portal cache"), err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// fresh returns true if the entry is younger than the given TTL.
+func (e *portalCacheEntry) fresh(ttl time.Duration) bool {
+	return time.Since(e.FetchedAt) < ttl
+}
+
+// fresh returns true if the entry is younger than the given TTL.
+func (e *portalListCacheEntry) fresh(ttl time.Duration) bool {
+	return time.Since(e.FetchedAt) < ttl
+}
@@ -0,0 +1,508 @@
+// Package portaltest provides a fake mod portal for testing code that talks to
+// the Factorio mod portal.
+package portaltest
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Mod is a mod that is served by the fake portal.
+type Mod struct {
+	Name        string
+	Title       string
+	Owner       string
+	Summary     string
+	Description string
+	Category    string
+	Tags        []string
+	Downloads   int
+	Homepage    string
+	SourceUrl   string
+	License     string
+	Releases    []Release
+}
+
+// A Release is a release of a mod that is served by the fake portal. The zip
+// file for the release is generated from its info.json fields.
+type Release struct {
+	Version         string
+	FactorioVersion string
+	Dependencies    []string
+	// The contents of changelog.txt, if any.
+	Changelog string
+}
+
+// An Upload is a mod zip file that was uploaded to the fake portal.
+type Upload struct {
+	Mod      string
+	FileName string
+	Data     []byte
+	// True if the upload published a new mod.
+	Publish bool
+}
+
+type pendingUpload struct {
+	mod     string
+	publish bool
+}
+
+// Server is a fake mod portal backed by an httptest.Server.
+type Server struct {
+	*httptest.Server
+
+	// Credentials that are required to download mods.
+	Username string
+	Token    string
+	// The API key that is required to upload mods.
+	ApiKey string
+	// The password that is required to log in, and the email authentication
+	// code that is also required if it is not empty.
+	Password string
+	AuthCode string
+
+	mu       sync.Mutex
+	mods     map[string]*Mod
+	corrupt  map[string][]byte
+	pending  map[string]pendingUpload
+	uploads  []Upload
+	requests []string
+
+	notModified int
+	unavailable bool
+	nextUpload  int
+}
+
+// NewServer starts a fake mod portal serving the given mods. The caller must
+// call Close when finished.
+func NewServer(mods ...Mod) *Server {
+	s := &Server{
+		Username: "username",
+		Token:    "token",
+		ApiKey:   "apikey",
+		Password: "password",
+		mods:     map[string]*Mod{},
+		corrupt:  map[string][]byte{},
+		pending:  map[string]pendingUpload{},
+	}
+	for _, mod := range mods {
+		s.AddMod(mod)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// AddMod adds a mod to the portal, replacing any existing mod with the same
+// name.
+func (s *Server) AddMod(mod Mod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mods[mod.Name] = &mod
+}
+
+// Mod returns the mod with the given name, including any changes that were
+// made through the API.
+func (s *Server) Mod(name string) (Mod, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if mod := s.mods[name]; mod != nil {
+		return *mod, true
+	}
+	return Mod{}, false
+}
+
+// Corrupt makes the portal serve the given data when the release is
+// downloaded, while still publishing the hash of the real zip file.
+func (s *Server) Corrupt(name string, version string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.corrupt[name+"_"+version] = data
+}
+
+// SetUnavailable makes every request fail as if the portal was down.
+func (s *Server) SetUnavailable(unavailable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unavailable = unavailable
+}
+
+// Uploads returns the files that have been uploaded to the portal.
+func (s *Server) Uploads() []Upload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Upload{}, s.uploads...)
+}
+
+// Requests returns the method and path of every request that the portal has
+// received, in the format of 'GET /api/mods/flib/full'.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// NotModified returns the number of requests that were answered with 304 Not
+// Modified.
+func (s *Server) NotModified() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notModified
+}
+
+// ReleaseZip returns the zip file that is served for the given release.
+func ReleaseZip(name string, release Release) []byte {
+	infoJson, _ := json.Marshal(map[string]any{
+		"name":             name,
+		"version":          release.Version,
+		"title":            name,
+		"author":           "portaltest",
+		"factorio_version": release.FactorioVersion,
+		"dependencies":     release.Dependencies,
+	})
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	file, _ := w.Create(fmt.Sprintf("%s_%s/info.json", name, release.Version))
+	file.Write(infoJson)
+	if release.Changelog != "" {
+		file, _ = w.Create(fmt.Sprintf("%s_%s/changelog.txt", name, release.Version))
+		file.Write([]byte(release.Changelog))
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	if s.unavailable {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/mods":
+		s.serveModList(w, r)
+	case r.Method == http.MethodGet && len(parts) == 4 && parts[0] == "api" && parts[1] == "mods" && parts[3] == "full":
+		s.serveModInfo(w, r, parts[2])
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "download":
+		s.serveDownload(w, r, parts[1], parts[2])
+	case r.Method == http.MethodPost && r.URL.Path == "/api-login":
+		s.serveLogin(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/mods/releases/init_upload":
+		s.serveInitUpload(w, r, false)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/mods/init_publish":
+		s.serveInitUpload(w, r, true)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/mods/edit_details":
+		s.serveEditDetails(w, r)
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[0] == "upload":
+		s.serveUpload(w, r, parts[1])
+	default:
+		writeError(w, http.StatusNotFound, "UnknownEndpoint", "Unknown endpoint")
+	}
+}
+
+func (s *Server) serveModInfo(w http.ResponseWriter, r *http.Request, name string) {
+	mod := s.mods[name]
+	if mod == nil {
+		writeError(w, http.StatusNotFound, "UnknownMod", "Mod not found")
+		return
+	}
+	body, _ := json.Marshal(s.modInfo(mod))
+	hash := sha1.Sum(body)
+	etag := "\"" + hex.EncodeToString(hash[:]) + "\""
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// serveModList serves every mod that has a release for the requested Factorio
+// version on a single page, regardless of the requested page size. If a
+// namelist is given, only those mods are served, with all of their releases
+// but without their dependencies.
+func (s *Server) serveModList(w http.ResponseWriter, r *http.Request) {
+	version := r.URL.Query().Get("version")
+	names := []string{}
+	if namelist := r.URL.Query().Get("namelist"); namelist != "" {
+		for _, name := range strings.Split(namelist, ",") {
+			if s.mods[name] != nil {
+				names = append(names, name)
+			}
+		}
+	} else {
+		for name := range s.mods {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	results := []map[string]any{}
+	for _, name := range names {
+		mod := s.mods[name]
+		var latest *Release
+		for i := range mod.Releases {
+			if version == "" || mod.Releases[i].FactorioVersion == version {
+				latest = &mod.Releases[i]
+			}
+		}
+		if latest == nil {
+			continue
+		}
+		result := map[string]any{
+			"name":            mod.Name,
+			"title":           mod.Title,
+			"owner":           mod.Owner,
+			"summary":         mod.Summary,
+			"category":        mod.Category,
+			"downloads_count": mod.Downloads,
+		}
+		if r.URL.Query().Has("namelist") {
+			releases := []map[string]any{}
+			for _, release := range mod.Releases {
+				info := releaseInfo(mod.Name, release)
+				info["info_json"] = map[string]any{"factorio_version": release.FactorioVersion}
+				releases = append(releases, info)
+			}
+			result["releases"] = releases
+		} else {
+			result["latest_release"] = releaseInfo(mod.Name, *latest)
+		}
+		results = append(results, result)
+	}
+	writeJson(w, http.StatusOK, map[string]any{
+		"pagination": map[string]any{
+			"count":      len(results),
+			"page":       1,
+			"page_count": 1,
+			"page_size":  len(results),
+		},
+		"results": results,
+	})
+}
+
+func releaseInfo(name string, release Release) map[string]any {
+	hash := sha1.Sum(ReleaseZip(name, release))
+	return map[string]any{
+		"download_url": fmt.Sprintf("/download/%s/%s", name, release.Version),
+		"file_name":    fmt.Sprintf("%s_%s.zip", name, release.Version),
+		"info_json": map[string]any{
+			"factorio_version": release.FactorioVersion,
+			"dependencies":     release.Dependencies,
+		},
+		"released_at": "2023-01-01T00:00:00.000000Z",
+		"version":     release.Version,
+		"sha1":        hex.EncodeToString(hash[:]),
+	}
+}
+
+func (s *Server) modInfo(mod *Mod) map[string]any {
+	releases := []map[string]any{}
+	for _, release := range mod.Releases {
+		releases = append(releases, releaseInfo(mod.Name, release))
+	}
+	info := map[string]any{
+		"name":       mod.Name,
+		"title":      mod.Title,
+		"owner":      mod.Owner,
+		"summary":    mod.Summary,
+		"homepage":   mod.Homepage,
+		"source_url": mod.SourceUrl,
+		"releases":   releases,
+	}
+	if len(mod.Releases) > 0 {
+		info["changelog"] = mod.Releases[len(mod.Releases)-1].Changelog
+	}
+	if mod.License != "" {
+		info["license"] = map[string]any{"name": mod.License, "title": mod.License, "url": ""}
+	}
+	return info
+}
+
+func (s *Server) serveDownload(w http.ResponseWriter, r *http.Request, name, version string) {
+	query := r.URL.Query()
+	if query.Get("username") != s.Username || query.Get("token") != s.Token {
+		writeError(w, http.StatusForbidden, "InvalidCredentials", "Invalid username or token")
+		return
+	}
+	if mod := s.mods[name]; mod != nil {
+		for _, release := range mod.Releases {
+			if release.Version == version {
+				data, ok := s.corrupt[name+"_"+version]
+				if !ok {
+					data = ReleaseZip(name, release)
+				}
+				w.Header().Set("Content-Type", "application/zip")
+				w.Write(data)
+				return
+			}
+		}
+	}
+	writeError(w, http.StatusNotFound, "UnknownMod", "Release not found")
+}
+
+// serveLogin stands in for the Factorio authentication server.
+func (s *Server) serveLogin(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("username") != s.Username || r.FormValue("password") != s.Password {
+		writeError(w, http.StatusUnauthorized, "login-failed", "Invalid username or password")
+		return
+	}
+	if s.AuthCode != "" && r.FormValue("email_authentication_code") != s.AuthCode {
+		writeError(w, http.StatusUnauthorized, "email-authentication-required", "Please enter the code that was sent to your email")
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]any{"token": s.Token, "username": s.Username})
+}
+
+func (s *Server) serveInitUpload(w http.ResponseWriter, r *http.Request, publish bool) {
+	if r.Header.Get("Authorization") != "Bearer "+s.ApiKey {
+		writeError(w, http.StatusForbidden, "InvalidApiKey", "Missing or invalid API key for the current endpoint")
+		return
+	}
+	name := r.FormValue("mod")
+	if publish && s.mods[name] != nil {
+		writeError(w, http.StatusBadRequest, "ModAlreadyExists", "Mod with this name already exists")
+		return
+	}
+	if !publish && s.mods[name] == nil {
+		writeError(w, http.StatusBadRequest, "UnknownMod", "Mod does not exist in mod portal")
+		return
+	}
+	s.nextUpload++
+	id := fmt.Sprint(s.nextUpload)
+	s.pending[id] = pendingUpload{name, publish}
+	writeJson(w, http.StatusOK, map[string]any{"upload_url": s.URL + "/upload/" + id})
+}
+
+func (s *Server) serveEditDetails(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+s.ApiKey {
+		writeError(w, http.StatusForbidden, "InvalidApiKey", "Missing or invalid API key for the current endpoint")
+		return
+	}
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", err.Error())
+		return
+	}
+	mod := s.mods[r.FormValue("mod")]
+	if mod == nil {
+		writeError(w, http.StatusBadRequest, "UnknownMod", "Mod does not exist in mod portal")
+		return
+	}
+	setDetails(mod, r, "title", "summary", "description", "category", "license", "homepage", "source_url")
+	if tags, ok := r.MultipartForm.Value["tags"]; ok {
+		mod.Tags = tags
+	}
+	writeJson(w, http.StatusOK, map[string]any{"success": true, "url": "/mod/" + mod.Name})
+}
+
+// setDetails sets the given fields of the mod from the form values of the
+// request. Empty values are ignored.
+func setDetails(mod *Mod, r *http.Request, keys ...string) {
+	fields := map[string]*string{
+		"title":       &mod.Title,
+		"summary":     &mod.Summary,
+		"description": &mod.Description,
+		"category":    &mod.Category,
+		"license":     &mod.License,
+		"homepage":    &mod.Homepage,
+		"source_url":  &mod.SourceUrl,
+	}
+	for _, key := range keys {
+		if value := r.FormValue(key); value != "" {
+			*fields[key] = value
+		}
+	}
+}
+
+func (s *Server) serveUpload(w http.ResponseWriter, r *http.Request, id string) {
+	pending, ok := s.pending[id]
+	name := pending.mod
+	if !ok {
+		writeError(w, http.StatusBadRequest, "InvalidUpload", "Invalid upload URL")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidModUpload", "No file was uploaded")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidModUpload", err.Error())
+		return
+	}
+	release, err := readRelease(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidModUpload", err.Error())
+		return
+	}
+	if pending.publish {
+		s.mods[name] = &Mod{Name: name, Title: name}
+		setDetails(s.mods[name], r, "description", "category", "license", "source_url")
+	}
+	mod := s.mods[name]
+	for _, existing := range mod.Releases {
+		if existing.Version == release.Version {
+			writeError(w, http.StatusBadRequest, "InvalidModRelease", "Mod release with this version already exists")
+			return
+		}
+	}
+	mod.Releases = append(mod.Releases, release)
+	delete(s.pending, id)
+	s.uploads = append(s.uploads, Upload{Mod: name, FileName: header.Filename, Data: data, Publish: pending.publish})
+	writeJson(w, http.StatusOK, map[string]any{"success": true})
+}
+
+// readRelease reads the release information from the info.json file of an
+// uploaded zip file.
+func readRelease(data []byte) (Release, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Release{}, err
+	}
+	for _, file := range r.File {
+		if parts := strings.Split(file.Name, "/"); len(parts) != 2 || parts[1] != "info.json" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return Release{}, err
+		}
+		defer rc.Close()
+		var info struct {
+			Version         string   `json:"version"`
+			FactorioVersion string   `json:"factorio_version"`
+			Dependencies    []string `json:"dependencies"`
+		}
+		if err := json.NewDecoder(rc).Decode(&info); err != nil {
+			return Release{}, err
+		}
+		return Release{Version: info.Version, FactorioVersion: info.FactorioVersion, Dependencies: info.Dependencies}, nil
+	}
+	return Release{}, fmt.Errorf("info.json was not found")
+}
+
+func writeJson(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJson(w, status, map[string]any{"error": code, "message": message})
+}
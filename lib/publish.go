@@ -0,0 +1,115 @@
+package fmm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// ModDetails are the details of a mod's page on the mod portal. Empty fields
+// are left unchanged when the details are edited.
+type ModDetails struct {
+	Title       string `json:"title,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	Description string `json:"description,omitempty"`
+	// If Description is empty, it is read from this file, relative to the
+	// details file.
+	DescriptionFile string   `json:"description_file,omitempty"`
+	Category        string   `json:"category,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	License         string   `json:"license,omitempty"`
+	Homepage        string   `json:"homepage,omitempty"`
+	SourceUrl       string   `json:"source_url,omitempty"`
+}
+
+// ParseModDetails reads mod details from the given JSON file.
+func ParseModDetails(path string) (*ModDetails, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var details ModDetails
+	if err := json.Unmarshal(data, &details); err != nil {
+		return nil, errors.Join(errors.New("invalid mod details format"), err)
+	}
+	if details.Description == "" && details.DescriptionFile != "" {
+		description, err := os.ReadFile(filepath.Join(filepath.Dir(path), details.DescriptionFile))
+		if err != nil {
+			return nil, errors.Join(errors.New("unable to read description file"), err)
+		}
+		details.Description = string(description)
+	}
+	return &details, nil
+}
+
+func (d *ModDetails) fields() url.Values {
+	fields := url.Values{}
+	set := func(key string, value string) {
+		if value != "" {
+			fields.Set(key, value)
+		}
+	}
+	set("title", d.Title)
+	set("summary", d.Summary)
+	set("description", d.Description)
+	set("category", d.Category)
+	set("license", d.License)
+	set("homepage", d.Homepage)
+	set("source_url", d.SourceUrl)
+	for _, tag := range d.Tags {
+		fields.Add("tags", tag)
+	}
+	return fields
+}
+
+// PublishMod publishes the given file to the mod portal as a new mod. If
+// details are given, they are applied to the new mod page. The file is
+// validated before anything is uploaded.
+func (p *ModPortal) PublishMod(path string, details *ModDetails) error {
+	info, err := validateModZip(path)
+	if err != nil {
+		return err
+	}
+	uploadUrl, err := p.initUpload("api/v2/mods/init_publish", info.Name)
+	if err != nil {
+		return err
+	}
+	if details == nil {
+		return p.finishUpload(uploadUrl, path, nil)
+	}
+
+	// Publishing only accepts some of the details, so the rest are edited
+	// afterwards
+	all, fields := details.fields(), url.Values{}
+	for _, key := range []string{"description", "category", "license", "source_url"} {
+		if value := all.Get(key); value != "" {
+			fields.Set(key, value)
+		}
+	}
+	if err := p.finishUpload(uploadUrl, path, fields); err != nil {
+		return err
+	}
+	if details.Title == "" && details.Summary == "" && len(details.Tags) == 0 && details.Homepage == "" {
+		return nil
+	}
+	return p.EditModDetails(info.Name, details)
+}
+
+// EditModDetails updates the mod portal page of the given mod with the given
+// details.
+func (p *ModPortal) EditModDetails(name string, details *ModDetails) error {
+	fields := details.fields()
+	fields.Set("mod", name)
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := p.postForm("api/v2/mods/edit_details", fields, &result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return errors.New("the mod portal did not accept the mod details")
+	}
+	return nil
+}
@@ -27,8 +27,10 @@ func releaseFromFile(path string) (*Release, error) {
 	if info.Mode().IsRegular() {
 		infoJson, err = readZipInfoJson(path)
 	} else if info.IsDir() || isSymlink(info) {
-		file, err := os.Open(filepath.Join(path, "info.json"))
+		var file *os.File
+		file, err = os.Open(filepath.Join(path, "info.json"))
 		if err == nil {
+			defer file.Close()
 			infoJson, err = readInfoJson(file)
 		}
 	}
@@ -36,6 +38,9 @@ func releaseFromFile(path string) (*Release, error) {
 	if err != nil {
 		return nil, errors.Join(errors.New("error when parsing info.json"), err)
 	}
+	if infoJson.Name == "" {
+		return nil, errors.New("info.json does not contain a name")
+	}
 
 	ident := NewModIdent(filename)
 	if ident.Name == infoJson.Name && infoJson.Version.Cmp(ident.Version) != VersionEq {
@@ -78,6 +83,7 @@ func readZipInfoJson(path string) (infoJson, error) {
 	if err != nil {
 		return infoJson{}, err
 	}
+	defer r.Close()
 
 	var file *zip.File
 
@@ -0,0 +1,179 @@
+package fmm
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The number of search results on each page by default.
+const DefaultSearchPageSize = 20
+
+// SearchOptions filters and paginates the results of ModPortal.Search. Empty
+// fields do not filter anything.
+type SearchOptions struct {
+	// Matched case-insensitively against the name, title and summary of each
+	// mod.
+	Query string
+	// Only mods with a release for this Factorio version are returned. If nil,
+	// the version of the base mod is used.
+	FactorioVersion *Version
+	Category        string
+	Owner           string
+	// The page to return, starting at 1.
+	Page     int
+	PageSize int
+}
+
+// A PortalModListing is a mod as it appears in the mod portal's list of mods.
+type PortalModListing struct {
+	Name           string            `json:"name"`
+	Title          string            `json:"title"`
+	Owner          string            `json:"owner"`
+	Summary        string            `json:"summary"`
+	Category       string            `json:"category"`
+	DownloadsCount int               `json:"downloads_count"`
+	LatestRelease  *PortalModRelease `json:"latest_release"`
+	// Only set when the list is requested by name. The releases do not
+	// include their dependencies.
+	Releases []PortalModRelease `json:"releases"`
+}
+
+// SearchResult is one page of the mods that matched a search.
+type SearchResult struct {
+	Mods []PortalModListing
+	// The page that was returned, the total number of pages, and the total
+	// number of mods that matched.
+	Page      int
+	PageCount int
+	Count     int
+}
+
+type portalModList struct {
+	Results []PortalModListing `json:"results"`
+}
+
+// Search lists the mods on the mod portal that match the given options, most
+// downloaded first. The Factorio version is filtered by the portal, and the
+// remaining filters are applied locally.
+func (p *ModPortal) Search(opts SearchOptions) (*SearchResult, error) {
+	factorioVersion := opts.FactorioVersion
+	if factorioVersion == nil {
+		factorioVersion = p.baseVersion
+	}
+	list, err := p.listMods(factorioVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	mods := []PortalModListing{}
+	for _, mod := range list {
+		if mod.matches(&opts) {
+			mods = append(mods, mod)
+		}
+	}
+	slices.SortStableFunc(mods, func(a, b PortalModListing) int {
+		if a.DownloadsCount != b.DownloadsCount {
+			return cmp.Compare(b.DownloadsCount, a.DownloadsCount)
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	pageSize := opts.PageSize
+	if pageSize < 1 {
+		pageSize = DefaultSearchPageSize
+	}
+	page := max(opts.Page, 1)
+	start := min((page-1)*pageSize, len(mods))
+	end := min(start+pageSize, len(mods))
+	return &SearchResult{
+		Mods:      mods[start:end],
+		Page:      page,
+		PageCount: (len(mods) + pageSize - 1) / pageSize,
+		Count:     len(mods),
+	}, nil
+}
+
+// listMods returns every mod on the mod portal that has a release for the given
+// Factorio version. The list is cached in the same way as the information of
+// each mod.
+func (p *ModPortal) listMods(factorioVersion *Version) ([]PortalModListing, error) {
+	version := ""
+	if factorioVersion != nil {
+		version = fmt.Sprintf("%d.%d", factorioVersion[0], factorioVersion[1])
+	}
+	entry := p.readListCache(version)
+	if p.offline {
+		if entry == nil {
+			return nil, fmt.Errorf("the list of mods is not in the portal cache: %w", ErrPortalOffline)
+		}
+		return entry.Results, nil
+	}
+	if entry != nil && entry.fresh(p.cacheTTL) {
+		return entry.Results, nil
+	}
+
+	list, err := p.fetchModList(version)
+	if err != nil {
+		if entry != nil {
+			// Fall back to the stale list during an outage
+			return entry.Results, nil
+		}
+		return nil, err
+	}
+	// The cache is only an optimization, so failing to write it is not an
+	// error
+	p.writeListCache(version, &portalListCacheEntry{FetchedAt: time.Now(), Results: list})
+	return list, nil
+}
+
+func (p *ModPortal) fetchModList(version string) ([]PortalModListing, error) {
+	listUrl, err := url.JoinPath(p.server, "api/mods")
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"page_size": {"max"}, "hide_deprecated": {"true"}}
+	if version != "" {
+		query.Set("version", version)
+	}
+	res, err := http.Get(listUrl + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Join(errors.New("failed to search the mod portal"), readPortalError(res))
+	}
+	var list portalModList
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	if list.Results == nil {
+		list.Results = []PortalModListing{}
+	}
+	return list.Results, nil
+}
+
+func (m *PortalModListing) matches(opts *SearchOptions) bool {
+	if opts.Query != "" {
+		query := strings.ToLower(opts.Query)
+		if !strings.Contains(strings.ToLower(m.Name), query) &&
+			!strings.Contains(strings.ToLower(m.Title), query) &&
+			!strings.Contains(strings.ToLower(m.Summary), query) {
+			return false
+		}
+	}
+	if opts.Category != "" && !strings.EqualFold(m.Category, opts.Category) {
+		return false
+	}
+	if opts.Owner != "" && !strings.EqualFold(m.Owner, opts.Owner) {
+		return false
+	}
+	return true
+}
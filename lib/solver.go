@@ -0,0 +1,449 @@
+package fmm
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The maximum number of candidate releases the solver will try before giving
+// up. This keeps pathological modpacks from backtracking forever.
+const solverMaxSteps = 100000
+
+// A Requirement is a constraint placed on a mod, either by the user or by the
+// dependencies of another mod.
+type Requirement struct {
+	// The release that declared the dependency, or nil if the mod was
+	// requested directly or pinned.
+	Dependent  *ModIdent
+	Dependency Dependency
+	// True if the requirement is a pin set by the user.
+	Pinned bool
+}
+
+func (r *Requirement) ToString() string {
+	dep := r.Dependency.ToString()
+	if r.Pinned {
+		return fmt.Sprint("pinned to ", dep)
+	}
+	if r.Dependent == nil {
+		return fmt.Sprint("requested ", dep)
+	}
+	return fmt.Sprint(r.Dependent.ToString(), " requires ", dep)
+}
+
+// ResolveError describes why a consistent set of releases could not be found.
+type ResolveError struct {
+	// The mod that could not be resolved.
+	Name string
+	// Every requirement placed on the mod at the time of the conflict.
+	Requirements []Requirement
+	// The versions that were considered for the mod.
+	Candidates []Version
+	// The error encountered when searching for releases, if there were none.
+	Err error
+}
+
+func (e *ResolveError) Error() string {
+	var b strings.Builder
+	if len(e.Candidates) == 0 {
+		fmt.Fprintf(&b, "unable to resolve %s: no releases were found", e.Name)
+		if e.Err != nil {
+			fmt.Fprintf(&b, " (%s)", e.Err)
+		}
+	} else {
+		fmt.Fprintf(&b, "unable to resolve %s: no release satisfies all requirements", e.Name)
+	}
+	for _, req := range e.Requirements {
+		fmt.Fprint(&b, "\n  ", req.ToString())
+	}
+	if len(e.Candidates) > 0 {
+		versions := make([]string, len(e.Candidates))
+		for i, ver := range e.Candidates {
+			versions[i] = ver.ToString(false)
+		}
+		fmt.Fprint(&b, "\n  available: ", strings.Join(versions, ", "))
+	}
+	return b.String()
+}
+
+func (e *ResolveError) Unwrap() error {
+	if e.Err != nil {
+		return e.Err
+	}
+	return ErrNoCompatibleRelease
+}
+
+// ResolveOptions controls which releases and dependencies are considered by
+// Resolve.
+type ResolveOptions struct {
+	// Consider releases from the mod portal if no local release matches.
+	FetchFromPortal bool
+	// Also resolve optional ("?") dependencies.
+	Optional bool
+	// Also resolve hidden optional ("(?)") dependencies.
+	HiddenOptional bool
+}
+
+// Resolve finds a release for each of the given mods and all of their
+// required dependencies such that every version constraint, including pins, is
+// satisfied.
+// Local releases are preferred over releases on the mod portal. The version
+// constraints of optional dependencies are always honoured when the
+// dependency is part of the result or already enabled, even if the optional
+// dependency is not resolved itself. Returns a *ResolveError if no consistent
+// set exists.
+func (m *Manager) Resolve(mods []ModIdent, opts ResolveOptions) ([]ModIdent, error) {
+	s := solver{
+		m:          m,
+		opts:       opts,
+		assigned:   map[string]*candidate{},
+		local:      map[string][]*candidate{},
+		portal:     map[string][]*candidate{},
+		portalErrs: map[string]error{},
+		kept:       map[string]bool{},
+		dependents: map[string][]*Release{},
+	}
+	for _, mod := range m.mods {
+		if release := mod.GetEnabledRelease(); release != nil {
+			s.kept[mod.Name] = true
+			for _, dep := range release.Dependencies {
+				if !slices.Contains(s.dependents[dep.Name], release) {
+					s.dependents[dep.Name] = append(s.dependents[dep.Name], release)
+				}
+			}
+		}
+	}
+	// Keep the order of requirements in errors stable
+	for _, releases := range s.dependents {
+		slices.SortFunc(releases, func(a, b *Release) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+	}
+	pending := []pendingMod{}
+	for _, mod := range mods {
+		req := Dependency{Name: mod.Name, Version: mod.Version, Kind: DependencyRequired, Req: VersionAny}
+		if mod.Version != nil {
+			req.Req = VersionEq
+		}
+		s.requested = append(s.requested, Requirement{Dependency: req})
+		pending = append(pending, pendingMod{mod.Name, false})
+		delete(s.kept, mod.Name)
+	}
+
+	if !s.solve(pending) {
+		if s.conflict == nil {
+			return nil, errors.New("dependency resolution took too long")
+		}
+		return nil, s.conflict
+	}
+
+	output := make([]ModIdent, len(s.order))
+	for i, name := range s.order {
+		output[i] = s.assigned[name].ident
+	}
+	return output, nil
+}
+
+type candidate struct {
+	ident ModIdent
+	deps  []*Dependency
+}
+
+// A mod that is waiting to be assigned a release. If an optional mod cannot be
+// resolved, it is left out instead of failing the resolution.
+type pendingMod struct {
+	name     string
+	optional bool
+}
+
+type solver struct {
+	m    *Manager
+	opts ResolveOptions
+
+	requested []Requirement
+	assigned  map[string]*candidate
+	order     []string
+	steps     int
+	conflict  *ResolveError
+
+	local      map[string][]*candidate
+	portal     map[string][]*candidate
+	portalErrs map[string]error
+
+	// The enabled mods that are not assigned or waiting to be assigned, whose
+	// enabled releases will stay enabled when the result is applied.
+	kept map[string]bool
+	// The enabled releases that depend on each mod.
+	dependents map[string][]*Release
+}
+
+// solve assigns a release to the first unassigned mod in pending, then
+// recurses. If no release of that mod leads to a solution, the assignment is
+// undone so that the caller can try its next candidate.
+func (s *solver) solve(pending []pendingMod) bool {
+	for len(pending) > 0 && s.assigned[pending[0].name] != nil {
+		pending = pending[1:]
+	}
+	if len(pending) == 0 {
+		return true
+	}
+
+	name := pending[0].name
+	reqs := s.requirements(name)
+	tried := []Version{}
+	for i := 0; ; i++ {
+		c := s.candidate(name, i)
+		if c == nil || s.steps >= solverMaxSteps {
+			break
+		}
+		s.steps++
+		tried = append(tried, *c.ident.Version)
+		if !satisfiesAll(c, reqs) || !s.consistent(c) {
+			continue
+		}
+		s.assigned[name] = c
+		s.order = append(s.order, name)
+		next := slices.Clone(pending[1:])
+		unkept := []string{}
+		for _, dep := range c.deps {
+			if s.follows(dep) {
+				next = append(next, pendingMod{dep.Name, !dep.isRequired()})
+				if s.kept[dep.Name] {
+					delete(s.kept, dep.Name)
+					unkept = append(unkept, dep.Name)
+				}
+			}
+		}
+		if s.solve(next) {
+			return true
+		}
+		for _, dep := range unkept {
+			s.kept[dep] = true
+		}
+		delete(s.assigned, name)
+		s.order = s.order[:len(s.order)-1]
+	}
+
+	if pending[0].optional && s.steps < solverMaxSteps {
+		// A skipped mod that is enabled stays enabled, unless it is still
+		// waiting to be assigned as the dependency of another mod
+		rest := pending[1:]
+		restore := s.m.mods[name] != nil && s.m.mods[name].Enabled != nil &&
+			!slices.ContainsFunc(rest, func(p pendingMod) bool { return p.name == name })
+		if restore {
+			s.kept[name] = true
+		}
+		if s.solve(rest) {
+			return true
+		}
+		if restore {
+			delete(s.kept, name)
+		}
+		return false
+	}
+
+	// Record the first conflict that is found, which is the most specific one
+	if s.conflict == nil && s.steps < solverMaxSteps {
+		s.conflict = &ResolveError{
+			Name:         name,
+			Requirements: reqs,
+			Candidates:   tried,
+			Err:          s.portalErrs[name],
+		}
+		if len(tried) == 0 && s.conflict.Err == nil {
+			s.conflict.Err = ErrModNotFoundLocal
+		}
+	}
+	return false
+}
+
+// requirements collects every constraint placed on the given mod by the
+// requested mods, the currently assigned releases, and the enabled releases
+// that are not part of the resolution.
+func (s *solver) requirements(name string) []Requirement {
+	reqs := []Requirement{}
+	for _, req := range s.requested {
+		if req.Dependency.Name == name {
+			reqs = append(reqs, req)
+		}
+	}
+	if pin := s.m.pins[name]; pin != nil {
+		reqs = append(reqs, Requirement{Dependency: *pin, Pinned: true})
+	}
+	for _, dependent := range s.order {
+		c := s.assigned[dependent]
+		for _, dep := range c.deps {
+			if dep.Name == name && dep.Kind != DependencyIncompatible {
+				reqs = append(reqs, Requirement{Dependent: &c.ident, Dependency: *dep})
+			}
+		}
+	}
+	for _, release := range s.dependents[name] {
+		if !s.kept[release.Name] {
+			continue
+		}
+		for _, dep := range release.Dependencies {
+			if dep.Name == name && dep.Kind != DependencyIncompatible {
+				reqs = append(reqs, Requirement{Dependent: &ModIdent{release.Name, &release.Version}, Dependency: *dep})
+			}
+		}
+	}
+	return reqs
+}
+
+// follows returns true if the given dependency should be resolved.
+func (s *solver) follows(dep *Dependency) bool {
+	switch dep.Kind {
+	case DependencyRequired, DependencyNoLoadOrder:
+		return true
+	case DependencyOptional:
+		return s.opts.Optional || s.opts.HiddenOptional
+	case DependencyHiddenOptional:
+		return s.opts.HiddenOptional
+	default:
+		return false
+	}
+}
+
+// consistent checks that the dependencies of the given candidate do not
+// conflict with any releases that have already been assigned or that will stay
+// enabled, and that none of those releases conflict with the candidate.
+func (s *solver) consistent(c *candidate) bool {
+	for _, dep := range c.deps {
+		var ver *Version
+		if other := s.assigned[dep.Name]; other != nil {
+			ver = other.ident.Version
+		} else if dep.isRequired() {
+			// Required dependencies will be assigned later
+			continue
+		} else if mod := s.m.mods[dep.Name]; mod != nil && mod.Enabled != nil {
+			ver = mod.Enabled
+		} else {
+			continue
+		}
+		if !compatible(dep, ver) {
+			return false
+		}
+	}
+	for _, other := range s.assigned {
+		for _, dep := range other.deps {
+			if dep.Name == c.ident.Name && !compatible(dep, c.ident.Version) {
+				return false
+			}
+		}
+	}
+	for _, release := range s.dependents[c.ident.Name] {
+		if !s.kept[release.Name] || release.Name == c.ident.Name {
+			continue
+		}
+		for _, dep := range release.Dependencies {
+			if dep.Name == c.ident.Name && !compatible(dep, c.ident.Version) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// candidate returns the i-th known release of the given mod, or nil if there
+// are no more. Local releases are tried newest first, followed by releases from
+// the mod portal, which is only queried once the local releases are exhausted.
+// If the mod is enabled and was not requested directly, the enabled release is
+// tried first so that dependencies are not changed unnecessarily.
+func (s *solver) candidate(name string, i int) *candidate {
+	local := s.localCandidates(name)
+	if i < len(local) {
+		return local[i]
+	}
+	if !s.opts.FetchFromPortal {
+		return nil
+	}
+	if mod, _ := s.m.GetMod(name); mod != nil && mod.isInternal {
+		return nil
+	}
+	portal := s.portalCandidates(name)
+	if i-len(local) < len(portal) {
+		return portal[i-len(local)]
+	}
+	return nil
+}
+
+func (s *solver) localCandidates(name string) []*candidate {
+	if candidates, ok := s.local[name]; ok {
+		return candidates
+	}
+	candidates := []*candidate{}
+	if mod, _ := s.m.GetMod(name); mod != nil {
+		enabled := mod.GetEnabledRelease()
+		if slices.ContainsFunc(s.requested, func(req Requirement) bool { return req.Dependency.Name == name }) {
+			enabled = nil
+		}
+		if enabled != nil {
+			candidates = append(candidates, &candidate{
+				ident: ModIdent{enabled.Name, &enabled.Version},
+				deps:  enabled.Dependencies,
+			})
+		}
+		for i := len(mod.releases) - 1; i >= 0; i-- {
+			release := mod.releases[i]
+			if release == enabled {
+				continue
+			}
+			candidates = append(candidates, &candidate{
+				ident: ModIdent{release.Name, &release.Version},
+				deps:  release.Dependencies,
+			})
+		}
+	}
+	s.local[name] = candidates
+	return candidates
+}
+
+func (s *solver) portalCandidates(name string) []*candidate {
+	if candidates, ok := s.portal[name]; ok {
+		return candidates
+	}
+	candidates := []*candidate{}
+	info, err := s.m.Portal.GetModInfo(name)
+	if err != nil {
+		s.portalErrs[name] = err
+	} else {
+		mod, _ := s.m.GetMod(name)
+		for i := len(info.Releases) - 1; i >= 0; i-- {
+			release := &info.Releases[i]
+			if !release.compatibleWithBaseVersion(s.m.Portal.baseVersion) {
+				continue
+			}
+			if mod != nil && mod.GetRelease(&release.Version) != nil {
+				continue
+			}
+			candidates = append(candidates, &candidate{
+				ident: ModIdent{name, &release.Version},
+				deps:  release.InfoJson.Dependencies,
+			})
+		}
+	}
+	s.portal[name] = candidates
+	return candidates
+}
+
+// compatible returns true if the given version of a mod can be loaded
+// alongside a release with the given dependency.
+func compatible(dep *Dependency, ver *Version) bool {
+	if dep.Kind == DependencyIncompatible {
+		return !dep.excludes(ver)
+	}
+	return dep.Test(ver)
+}
+
+func satisfiesAll(c *candidate, reqs []Requirement) bool {
+	for _, req := range reqs {
+		if !req.Dependency.Test(c.ident.Version) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package fmm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newMemoryManager creates a Manager with the given releases, each in the
+// format 'name_version' followed by its dependency strings.
+func newMemoryManager(t *testing.T, releases ...[]string) *Manager {
+	m := &Manager{mods: map[string]*Mod{}}
+	for _, spec := range releases {
+		ident := NewModIdent(spec[0])
+		require.NotNil(t, ident.Version)
+		release := &Release{Name: ident.Name, Path: spec[0] + ".zip", Version: *ident.Version}
+		for _, input := range spec[1:] {
+			dep, err := NewDependency(input)
+			require.NoError(t, err)
+			release.Dependencies = append(release.Dependencies, dep)
+		}
+		m.addRelease(release, ident.Name == "base")
+	}
+	return m
+}
+
+func TestResolve(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"base_1.1.0"},
+		[]string{"flib_0.11.0"},
+		[]string{"flib_0.12.0"},
+		[]string{"flib_0.13.0"},
+		[]string{"flib_0.14.0"},
+		[]string{"A_1.0.0", "base >= 1.1", "flib >= 0.12"},
+		[]string{"B_1.0.0", "flib < 0.14", "? C"},
+	)
+
+	resolved, err := m.Resolve([]ModIdent{{Name: "A"}, {Name: "B"}}, ResolveOptions{})
+	require.NoError(t, err)
+	versions := map[string]string{}
+	for _, mod := range resolved {
+		versions[mod.Name] = mod.Version.ToString(false)
+	}
+	require.Equal(t, map[string]string{
+		"A":    "1.0.0",
+		"B":    "1.0.0",
+		"base": "1.1.0",
+		"flib": "0.13.0",
+	}, versions)
+}
+
+func TestResolveBacktrack(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"A_1.0.0", "B", "C"},
+		[]string{"B_1.0.0", "D = 1.0"},
+		[]string{"B_2.0.0", "D = 2.0"},
+		[]string{"C_1.0.0", "D < 2.0"},
+		[]string{"D_1.0.0"},
+		[]string{"D_2.0.0"},
+	)
+
+	resolved, err := m.Resolve([]ModIdent{{Name: "A"}}, ResolveOptions{})
+	require.NoError(t, err)
+	for _, mod := range resolved {
+		if mod.Name == "B" || mod.Name == "D" {
+			require.Equal(t, VersionEq, mod.Version.Cmp(&Version{1}))
+		}
+	}
+	require.Len(t, resolved, 4)
+}
+
+func TestResolveConflict(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"flib_0.11.0"},
+		[]string{"flib_0.14.0"},
+		[]string{"A_1.0.0", "flib >= 0.12"},
+		[]string{"B_1.0.0", "flib < 0.14"},
+	)
+
+	_, err := m.Resolve([]ModIdent{{Name: "A"}, {Name: "B"}}, ResolveOptions{})
+	var resolveErr *ResolveError
+	require.ErrorAs(t, err, &resolveErr)
+	require.Equal(t, "flib", resolveErr.Name)
+	require.Len(t, resolveErr.Requirements, 2)
+	require.Len(t, resolveErr.Candidates, 2)
+	require.True(t, errors.Is(err, ErrNoCompatibleRelease))
+
+	_, err = m.Resolve([]ModIdent{{Name: "missing"}}, ResolveOptions{})
+	require.True(t, errors.Is(err, ErrModNotFoundLocal))
+}
+
+func TestResolveOptional(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"A_1.0.0", "? B >= 2.0", "? C", "(?) D"},
+		[]string{"B_1.0.0"},
+		[]string{"B_2.0.0"},
+		[]string{"D_1.0.0"},
+	)
+	names := func(mods []ModIdent) []string {
+		output := []string{}
+		for _, mod := range mods {
+			output = append(output, mod.ToString())
+		}
+		return output
+	}
+
+	resolved, err := m.Resolve([]ModIdent{{Name: "A"}}, ResolveOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"A 1.0.0"}, names(resolved))
+
+	resolved, err = m.Resolve([]ModIdent{{Name: "A"}}, ResolveOptions{Optional: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"A 1.0.0", "B 2.0.0"}, names(resolved))
+
+	resolved, err = m.Resolve([]ModIdent{{Name: "A"}}, ResolveOptions{HiddenOptional: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"A 1.0.0", "B 2.0.0", "D 1.0.0"}, names(resolved))
+
+	// The constraints of optional dependencies apply to mods in the result
+	_, err = m.Resolve([]ModIdent{{Name: "A"}, {Name: "B", Version: &Version{1}}}, ResolveOptions{})
+	require.ErrorIs(t, err, ErrNoCompatibleRelease)
+
+	// And to mods that are already enabled
+	_, err = m.Enable(ModIdent{Name: "B", Version: &Version{1}})
+	require.NoError(t, err)
+	_, err = m.Resolve([]ModIdent{{Name: "A"}}, ResolveOptions{})
+	require.ErrorIs(t, err, ErrNoCompatibleRelease)
+}
+
+func TestResolveEnabled(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"flib_0.13.0"},
+		[]string{"flib_0.14.0"},
+		[]string{"X_1.0.0", "flib >= 0.14"},
+		[]string{"Y_1.0.0", "flib < 0.14"},
+		[]string{"Z_1.0.0", "flib >= 0.13"},
+		[]string{"U_1.0.0", "flib < 0.14"},
+		[]string{"U_2.0.0", "flib >= 0.13"},
+		[]string{"W_1.0.0", "flib >= 0.14", "U >= 2.0"},
+	)
+	_, err := m.Enable(ModIdent{Name: "flib", Version: &Version{0, 13}})
+	require.NoError(t, err)
+	_, err = m.Enable(ModIdent{Name: "Y"})
+	require.NoError(t, err)
+
+	// The required dependencies of enabled mods must stay satisfied
+	_, err = m.Resolve([]ModIdent{{Name: "X"}}, ResolveOptions{})
+	var resolveErr *ResolveError
+	require.ErrorAs(t, err, &resolveErr)
+	require.Equal(t, "flib", resolveErr.Name)
+	require.Len(t, resolveErr.Requirements, 2)
+
+	// The enabled release of a dependency is kept if it satisfies every
+	// constraint
+	require.NoError(t, m.Disable("Y"))
+	resolved, err := m.Resolve([]ModIdent{{Name: "Z"}}, ResolveOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Z 1.0.0", "flib 0.13.0"}, []string{resolved[0].ToString(), resolved[1].ToString()})
+
+	// Enabled mods that are part of the result are not held to the
+	// constraints of their enabled release
+	_, err = m.Enable(ModIdent{Name: "U", Version: &Version{1}})
+	require.NoError(t, err)
+	resolved, err = m.Resolve([]ModIdent{{Name: "W"}}, ResolveOptions{})
+	require.NoError(t, err)
+	require.Len(t, resolved, 3)
+}
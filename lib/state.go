@@ -0,0 +1,231 @@
+package fmm
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+)
+
+const stateJsonFilename = "fmm-state.json"
+
+// stateJson stores information about the enabled mods that Factorio does not
+// track in mod-list.json.
+type stateJson struct {
+	// Enabled mods that were requested by the user.
+	Explicit []string `json:"explicit"`
+	// Enabled mods that were only enabled to satisfy the dependencies of
+	// another mod.
+	Dependencies []string `json:"dependencies"`
+	// Version constraints that are applied whenever mods are added or updated.
+	Pins []*Dependency `json:"pins,omitempty"`
+}
+
+func parseStateJson(path string) (*stateJson, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, errors.Join(errors.New("error reading "+stateJsonFilename), err)
+	}
+
+	var state stateJson
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, errors.Join(errors.New("error parsing "+stateJsonFilename), err)
+	}
+	return &state, nil
+}
+
+func (m *Manager) parseState() error {
+	state, err := parseStateJson(m.statePath)
+	if err != nil || state == nil {
+		return err
+	}
+	for _, name := range state.Dependencies {
+		if mod := m.mods[name]; mod != nil && mod.Enabled != nil {
+			mod.auto = true
+		}
+	}
+	for _, pin := range state.Pins {
+		m.pins[pin.Name] = pin
+	}
+	return nil
+}
+
+func (m *Manager) saveState() error {
+	state := stateJson{Explicit: []string{}, Dependencies: []string{}}
+	for name, mod := range m.mods {
+		if mod.Enabled == nil || mod.isInternal {
+			continue
+		}
+		if mod.auto {
+			state.Dependencies = append(state.Dependencies, name)
+		} else {
+			state.Explicit = append(state.Explicit, name)
+		}
+	}
+	for _, pin := range m.pins {
+		state.Pins = append(state.Pins, pin)
+	}
+	slices.Sort(state.Explicit)
+	slices.Sort(state.Dependencies)
+	slices.SortFunc(state.Pins, func(a, b *Dependency) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	marshaled, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(m.statePath, marshaled, 0666); err != nil {
+		return errors.Join(errors.New("failed to write "+stateJsonFilename), err)
+	}
+	return nil
+}
+
+// IsAuto returns true if the given mod was enabled only to satisfy the
+// dependencies of another mod.
+func (m *Manager) IsAuto(name string) bool {
+	mod := m.mods[name]
+	return mod != nil && mod.auto
+}
+
+// SetAuto records whether the given mod was enabled only to satisfy the
+// dependencies of another mod. Mods that are not marked as automatic are never
+// removed by Autoremove.
+func (m *Manager) SetAuto(name string, auto bool) error {
+	mod, err := m.GetMod(name)
+	if err != nil {
+		return err
+	}
+	mod.auto = auto
+	return nil
+}
+
+// GetAutoremovable returns the enabled mods that were enabled automatically and
+// are no longer a required or optional dependency of any other enabled mod.
+func (m *Manager) GetAutoremovable() []ModIdent {
+	removed := map[string]bool{}
+	for {
+		required := map[string]bool{}
+		for _, mod := range m.mods {
+			release := mod.GetEnabledRelease()
+			if release == nil || removed[mod.Name] {
+				continue
+			}
+			for _, dep := range release.Dependencies {
+				// Optional dependencies may have been enabled with --optional
+				if dep.Kind != DependencyIncompatible {
+					required[dep.Name] = true
+				}
+			}
+		}
+		changed := false
+		for _, mod := range m.mods {
+			if mod.auto && mod.Enabled != nil && !removed[mod.Name] && !required[mod.Name] {
+				removed[mod.Name] = true
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	output := []ModIdent{}
+	for name := range removed {
+		output = append(output, ModIdent{name, m.mods[name].Enabled})
+	}
+	slices.SortFunc(output, func(a, b ModIdent) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return output
+}
+
+// Autoremove disables every mod returned by GetAutoremovable. If doDelete is
+// true, every release of those mods is deleted as well. Returns the mods that
+// were disabled.
+func (m *Manager) Autoremove(doDelete bool) ([]ModIdent, error) {
+	mods := m.GetAutoremovable()
+	var errs []error
+	for _, ident := range mods {
+		mod := m.mods[ident.Name]
+		mod.Enabled = nil
+		mod.auto = false
+		if !doDelete {
+			continue
+		}
+		for len(mod.releases) > 0 {
+			if err := m.removeRelease(mod, mod.releases[0]); err != nil {
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+	return mods, errors.Join(errs...)
+}
+
+// GetPin returns the version constraint that the given mod is pinned to, if
+// any.
+func (m *Manager) GetPin(name string) *Dependency {
+	return m.pins[name]
+}
+
+// GetPins returns every pinned version constraint.
+func (m *Manager) GetPins() []*Dependency {
+	pins := []*Dependency{}
+	for _, pin := range m.pins {
+		pins = append(pins, pin)
+	}
+	slices.SortFunc(pins, func(a, b *Dependency) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return pins
+}
+
+// Pin constrains the versions of a mod that may be added or updated to. The
+// pin replaces any existing pin on the same mod.
+func (m *Manager) Pin(pin Dependency) {
+	pin.Kind = DependencyRequired
+	if m.pins == nil {
+		m.pins = map[string]*Dependency{}
+	}
+	m.pins[pin.Name] = &pin
+}
+
+// Unpin removes the pin on the given mod.
+func (m *Manager) Unpin(name string) error {
+	if m.pins[name] == nil {
+		return ErrModNotPinned
+	}
+	delete(m.pins, name)
+	return nil
+}
+
+// GetHeldBack returns the newest known release of the given mod if it is newer
+// than the given version but excluded by the mod's pin. Only local releases and
+// portal releases that have already been fetched are considered.
+func (m *Manager) GetHeldBack(ident ModIdent) *Version {
+	pin := m.pins[ident.Name]
+	if pin == nil || ident.Version == nil {
+		return nil
+	}
+	var newest *Version
+	consider := func(ver *Version) {
+		if !pin.Test(ver) && ver.Cmp(ident.Version) == VersionGt && (newest == nil || ver.Cmp(newest) == VersionGt) {
+			newest = ver
+		}
+	}
+	if mod := m.mods[ident.Name]; mod != nil {
+		consider(&mod.GetLatestRelease().Version)
+	}
+	releases := m.Portal.knownReleases(ident.Name)
+	for i := range releases {
+		if releases[i].compatibleWithBaseVersion(m.Portal.baseVersion) {
+			consider(&releases[i].Version)
+		}
+	}
+	return newest
+}
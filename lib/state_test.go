@@ -0,0 +1,86 @@
+package fmm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoremove(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"bigmod_1.0.0", "mid"},
+		[]string{"mid_1.0.0", "flib"},
+		[]string{"other_1.0.0", "shared", "? extra", "(?) hidden"},
+		[]string{"flib_1.0.0"},
+		[]string{"shared_1.0.0"},
+		[]string{"extra_1.0.0"},
+		[]string{"hidden_1.0.0"},
+		[]string{"orphan_1.0.0"},
+	)
+	m.modsPath = t.TempDir()
+	m.statePath = filepath.Join(m.modsPath, stateJsonFilename)
+	for _, name := range []string{"bigmod", "mid", "other", "flib", "shared", "extra", "hidden", "orphan"} {
+		_, err := m.Enable(ModIdent{Name: name})
+		require.NoError(t, err)
+	}
+	// Optional dependencies are automatic if they were added with --optional
+	for _, name := range []string{"mid", "flib", "shared", "extra", "hidden"} {
+		require.NoError(t, m.SetAuto(name, true))
+	}
+
+	require.NoError(t, m.saveState())
+	for _, mod := range m.mods {
+		mod.auto = false
+	}
+	require.NoError(t, m.parseState())
+	require.True(t, m.IsAuto("mid"))
+	require.False(t, m.IsAuto("orphan"))
+
+	require.NoError(t, m.Disable("bigmod"))
+	removable := m.GetAutoremovable()
+	require.Len(t, removable, 2)
+	require.Equal(t, "flib", removable[0].Name)
+	require.Equal(t, "mid", removable[1].Name)
+
+	require.NoError(t, os.WriteFile(filepath.Join(m.modsPath, "flib_1.0.0.zip"), []byte{}, 0666))
+	require.NoError(t, os.WriteFile(filepath.Join(m.modsPath, "mid_1.0.0.zip"), []byte{}, 0666))
+	removed, err := m.Autoremove(true)
+	require.NoError(t, err)
+	require.Equal(t, removable, removed)
+	require.Nil(t, m.mods["flib"])
+	require.NoFileExists(t, filepath.Join(m.modsPath, "flib_1.0.0.zip"))
+	require.NotNil(t, m.mods["shared"].Enabled)
+}
+
+func TestPins(t *testing.T) {
+	m := newMemoryManager(t,
+		[]string{"flib_0.12.0"},
+		[]string{"flib_0.13.0"},
+		[]string{"flib_0.14.0"},
+		[]string{"A_1.0.0", "flib >= 0.12"},
+	)
+	m.statePath = filepath.Join(t.TempDir(), stateJsonFilename)
+	m.pins = map[string]*Dependency{}
+
+	pin, err := NewDependency("flib<0.14")
+	require.NoError(t, err)
+	m.Pin(*pin)
+	require.NoError(t, m.saveState())
+	m.pins = map[string]*Dependency{}
+	require.NoError(t, m.parseState())
+	require.Equal(t, "flib < 0.14.0", m.GetPin("flib").ToString())
+
+	resolved, err := m.Resolve([]ModIdent{{Name: "A"}}, ResolveOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "flib 0.13.0", resolved[1].ToString())
+	require.Equal(t, Version{0, 14}, *m.GetHeldBack(resolved[1]))
+	require.Nil(t, m.GetHeldBack(resolved[0]))
+
+	_, err = m.Resolve([]ModIdent{{"flib", &Version{0, 14}}}, ResolveOptions{})
+	require.ErrorContains(t, err, "pinned to flib < 0.14.0")
+
+	require.NoError(t, m.Unpin("flib"))
+	require.ErrorIs(t, m.Unpin("flib"), ErrModNotPinned)
+}